@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/app"
+	"github.com/avraam311/image-processor/internal/auth"
+	"github.com/avraam311/image-processor/internal/infra/queue"
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/models"
+	"github.com/avraam311/image-processor/internal/repository/images"
+	"github.com/avraam311/image-processor/internal/repository/keys"
+
+	"github.com/wb-go/wbf/config"
+	"github.com/wb-go/wbf/zlog"
+)
+
+const usage = `usage: admin <command> [flags]
+
+commands:
+  dlq-replay   move dead-lettered jobs back to the main queue
+  key-create   create an API key and print it once
+  key-list     list API keys
+  key-revoke   revoke an API key
+  key-quota    set the upload quotas of an API key
+  migrate-objects
+               move objects stored under serial image ids to public id keys
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	zlog.Init()
+	cfg, err := app.LoadConfig(app.ConfigFilePath)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to load config")
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "dlq-replay":
+		err = replayDLQ(ctx, cfg, args)
+	case "key-create":
+		err = createKey(ctx, cfg, args)
+	case "key-list":
+		err = listKeys(ctx, cfg)
+	case "key-revoke":
+		err = revokeKey(ctx, cfg, args)
+	case "key-quota":
+		err = setKeyQuota(ctx, cfg, args)
+	case "migrate-objects":
+		err = migrateObjects(ctx, cfg, args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Str("command", command).Msg("command failed")
+	}
+}
+
+func replayDLQ(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("dlq-replay", flag.ExitOnError)
+	limit := flags.Int("limit", 0, "replay at most this many messages, 0 replays all")
+	idle := flags.Duration("idle", 10*time.Second, "stop when no message arrives for this long")
+	dryRun := flags.Bool("dry-run", false, "only log the dead letters without replaying them")
+	_ = flags.Parse(args)
+
+	db, err := app.ConnectDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer app.CloseDB(db)
+
+	dlq, err := queue.NewDeadLetter(cfg, db, cfg.GetString("queue.dlq_name"), cfg.GetString("kafka.dlq_group_id"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := dlq.Close(); err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to close dead-letter queue")
+		}
+	}()
+	jobs, err := queue.New(cfg, db, cfg.GetString("queue.name"), cfg.GetString("kafka.group_id"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := jobs.Close(); err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to close job queue")
+		}
+	}()
+
+	replayed, err := queue.Replay(ctx, dlq, jobs, queue.ReplayOptions{
+		Limit:  *limit,
+		Idle:   *idle,
+		DryRun: *dryRun,
+	})
+	zlog.Logger.Info().Int("replayed", replayed).Bool("dry_run", *dryRun).Msg("dead letters replayed")
+
+	return err
+}
+
+func createKey(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("key-create", flag.ExitOnError)
+	name := flags.String("name", "", "who or what the key is for")
+	_ = flags.Parse(args)
+	if *name == "" {
+		return fmt.Errorf("key-create - -name is required")
+	}
+
+	db, err := app.ConnectDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer app.CloseDB(db)
+
+	key, hash, prefix, err := auth.GenerateKey()
+	if err != nil {
+		return err
+	}
+	apiKey, err := keys.NewRepository(db).CreateKey(ctx, *name, prefix, hash)
+	if err != nil {
+		return err
+	}
+
+	// Only the hash is stored, the key can't be shown again.
+	fmt.Printf("id:   %d\nname: %s\nkey:  %s\n", apiKey.ID, apiKey.Name, key)
+
+	return nil
+}
+
+func listKeys(ctx context.Context, cfg *config.Config) error {
+	db, err := app.ConnectDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer app.CloseDB(db)
+
+	apiKeys, err := keys.NewRepository(db).ListKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tCREATED\tREVOKED\tMAX IMAGES\tMAX BYTES\tMAX JOBS/DAY")
+	for _, k := range apiKeys {
+		revoked := "-"
+		if k.RevokedAt != nil {
+			revoked = k.RevokedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Prefix, k.CreatedAt.Format(time.RFC3339), revoked,
+			formatLimit(k.Quota.MaxImages), formatLimit(k.Quota.MaxBytes), formatLimit(k.Quota.MaxJobsPerDay))
+	}
+
+	return w.Flush()
+}
+
+// formatLimit shows a quota limit of key-list.
+func formatLimit(limit *int64) string {
+	switch {
+	case limit == nil:
+		return "default"
+	case *limit == 0:
+		return "unlimited"
+	}
+
+	return strconv.FormatInt(*limit, 10)
+}
+
+func setKeyQuota(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("key-quota", flag.ExitOnError)
+	id := flags.Uint("id", 0, "id of the key")
+	maxImages := flags.Int64("max-images", -1, "images the key may store, 0 is unlimited, -1 the configured default")
+	maxBytes := flags.Int64("max-bytes", -1, "bytes the key may store, 0 is unlimited, -1 the configured default")
+	maxJobs := flags.Int64("max-jobs-per-day", -1, "uploads per UTC day, 0 is unlimited, -1 the configured default")
+	_ = flags.Parse(args)
+	if *id == 0 {
+		return fmt.Errorf("key-quota - -id is required")
+	}
+
+	db, err := app.ConnectDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer app.CloseDB(db)
+
+	limit := func(v int64) *int64 {
+		if v < 0 {
+			return nil
+		}
+		return &v
+	}
+	limits := models.QuotaLimits{
+		MaxImages:     limit(*maxImages),
+		MaxBytes:      limit(*maxBytes),
+		MaxJobsPerDay: limit(*maxJobs),
+	}
+	if err := keys.NewRepository(db).SetQuota(ctx, *id, limits); err != nil {
+		return err
+	}
+	zlog.Logger.Info().Uint("id", *id).Msg("api key quota set")
+
+	return nil
+}
+
+func revokeKey(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("key-revoke", flag.ExitOnError)
+	id := flags.Uint("id", 0, "id of the key to revoke")
+	_ = flags.Parse(args)
+	if *id == 0 {
+		return fmt.Errorf("key-revoke - -id is required")
+	}
+
+	db, err := app.ConnectDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer app.CloseDB(db)
+
+	if err := keys.NewRepository(db).RevokeKey(ctx, *id); err != nil {
+		return err
+	}
+	zlog.Logger.Info().Uint("id", *id).Msg("api key revoked")
+
+	return nil
+}
+
+// migrateObjects moves the originals and variants of images uploaded before
+// images had public ids to their new keys, including the single object of
+// images stored before originals and variants were split. Moved objects are
+// skipped, so it can be run again after a failure.
+func migrateObjects(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("migrate-objects", flag.ExitOnError)
+	batch := flags.Int("batch", 100, "images read per query")
+	dryRun := flags.Bool("dry-run", false, "only log the objects without moving them")
+	_ = flags.Parse(args)
+
+	db, err := app.ConnectDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer app.CloseDB(db)
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		return err
+	}
+	repo := images.NewRepository(db)
+
+	moved := 0
+	var after int64
+	for {
+		legacy, err := repo.ListLegacyImages(ctx, after, *batch)
+		if err != nil {
+			return err
+		}
+		if len(legacy) == 0 {
+			break
+		}
+
+		for _, im := range legacy {
+			moves := map[string]string{storage.LegacyOriginalKey(im.ID): storage.OriginalKey(im.PublicID)}
+			if im.Processed {
+				moves[storage.BaselineKey(im.ID)] = storage.VariantKey(im.PublicID, im.Variant)
+			} else {
+				moves[storage.BaselineKey(im.ID)] = storage.OriginalKey(im.PublicID)
+			}
+			variants, err := store.List(ctx, storage.LegacyVariantsPrefix(im.ID))
+			if err != nil {
+				return err
+			}
+			for _, variant := range variants {
+				name := strings.TrimPrefix(variant.Key, storage.LegacyVariantsPrefix(im.ID))
+				moves[variant.Key] = storage.VariantKey(im.PublicID, name)
+			}
+
+			for from, to := range moves {
+				if *dryRun {
+					zlog.Logger.Info().Str("from", from).Str("to", to).Msg("object to move")
+					continue
+				}
+				err := storage.Move(ctx, store, from, to)
+				if errors.Is(err, storage.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				moved++
+			}
+		}
+		after = legacy[len(legacy)-1].ID
+	}
+	zlog.Logger.Info().Int("moved", moved).Bool("dry_run", *dryRun).Msg("objects migrated")
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/avraam311/image-processor/internal/app"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+const usage = `usage: image-processor <command> [flags]
+
+commands:
+  serve        run the API server, with --with-worker also the workers
+  worker       run the workers
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	zlog.Init()
+
+	files := []string{app.ConfigFilePath}
+	var opts app.Options
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "serve":
+		flags := flag.NewFlagSet("serve", flag.ExitOnError)
+		withWorker := flags.Bool("with-worker", false, "run the workers in the same process, connected through an in-memory queue and local storage")
+		_ = flags.Parse(args)
+
+		opts.API, opts.Worker = true, *withWorker
+		if *withWorker {
+			files = append(files, app.StandaloneConfigFilePath)
+		}
+	case "worker":
+		opts.Worker = true
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := app.LoadConfig(files...)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to load config")
+	}
+	if err := app.Run(ctx, cfg, opts); err != nil {
+		zlog.Logger.Fatal().Err(err).Str("command", command).Msg("command failed")
+	}
+}
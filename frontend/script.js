@@ -4,6 +4,7 @@ document.getElementById('uploadForm').addEventListener('submit', async function(
     const formData = new FormData();
     const imageFile = document.getElementById('image').files[0];
     const processing = document.getElementById('processing').value;
+    const headers = authHeaders();
 
     if (!imageFile) {
         showStatus('Please select an image file.', 'error');
@@ -12,6 +13,11 @@ document.getElementById('uploadForm').addEventListener('submit', async function(
 
     formData.append('image', imageFile);
     formData.append('processing', processing);
+    if (processing === 'resize') {
+        formData.append('width', document.getElementById('width').value);
+        formData.append('height', document.getElementById('height').value);
+        formData.append('fit', document.getElementById('fit').value);
+    }
 
     showStatus('Uploading and processing image...', '');
 
@@ -19,6 +25,7 @@ document.getElementById('uploadForm').addEventListener('submit', async function(
         // Upload image
         const uploadResponse = await fetch('http://localhost:8080/image-processor/api/upload', {
             method: 'POST',
+            headers: headers,
             body: formData
         });
 
@@ -27,22 +34,27 @@ document.getElementById('uploadForm').addEventListener('submit', async function(
         }
 
         const uploadResult = await uploadResponse.json();
-        const imageId = uploadResult.id;
+        const imageId = uploadResult.result;
 
         showStatus(`Image uploaded with ID: ${imageId}. Processing...`, 'success');
 
         // Poll for processed image
-        pollForImage(imageId);
+        pollForImage(imageId, headers);
 
     } catch (error) {
         showStatus(`Error: ${error.message}`, 'error');
     }
 });
 
-function pollForImage(id) {
+function authHeaders() {
+    const key = document.getElementById('apiKey').value.trim();
+    return key ? { 'Authorization': `Bearer ${key}` } : {};
+}
+
+function pollForImage(id, headers) {
     const pollInterval = setInterval(async () => {
         try {
-            const response = await fetch(`http://localhost:8080/image-processor/api/image/${id}`);
+            const response = await fetch(`http://localhost:8080/image-processor/api/image/${id}`, { headers: headers });
 
             if (response.status === 200) {
                 clearInterval(pollInterval);
@@ -52,6 +64,10 @@ function pollForImage(id) {
                 showStatus('Image processed successfully!', 'success');
             } else if (response.status === 503) {
                 showStatus('Image is still processing...', '');
+            } else if (response.status === 422) {
+                clearInterval(pollInterval);
+                const failure = await response.json();
+                showStatus(`Processing failed (${failure.error.code}): ${failure.error.message}`, 'error');
             } else {
                 throw new Error(`Failed to get image: ${response.statusText}`);
             }
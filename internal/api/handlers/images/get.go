@@ -7,38 +7,136 @@ import (
 	"strconv"
 
 	"github.com/avraam311/image-processor/internal/api/handlers"
+	"github.com/avraam311/image-processor/internal/models"
 	"github.com/avraam311/image-processor/internal/repository/images"
+	service "github.com/avraam311/image-processor/internal/service/images"
 
 	"github.com/wb-go/wbf/ginext"
 	"github.com/wb-go/wbf/zlog"
+
+	"github.com/google/uuid"
+)
+
+const (
+	imageStatusFailed = "failed"
+	cacheControl      = "private, max-age=86400"
 )
 
 func (h *Handler) GetProcessedImage(c *ginext.Context) {
-	idStr := c.Param("id")
-	idInt, err := strconv.Atoi(idStr)
-	if err != nil {
-		zlog.Logger.Warn().Err(err).Msg("id is not proper unsigned integer or empty parameter")
-		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("non-empty and proper id required"))
+	id, ok := parseID(c)
+	if !ok {
 		return
 	}
-	id := uint(idInt)
 
 	im, err := h.service.GetProcessedImage(c.Request.Context(), id)
 	if err != nil {
-		if errors.Is(err, images.ErrImageNotFound) {
-			zlog.Logger.Warn().Err(err).Msg("image not found")
-			handlers.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("image not found"))
-			return
-		} else if errors.Is(err, images.ErrImageInProcess) {
-			zlog.Logger.Warn().Err(err).Msg("image in process")
-			handlers.Fail(c.Writer, http.StatusServiceUnavailable, fmt.Errorf("image in process"))
-			return
-		}
+		failGetImage(c, err)
+		return
+	}
+
+	writeImage(c, im)
+}
+
+func (h *Handler) GetOriginalImage(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	im, err := h.service.GetOriginalImage(c.Request.Context(), id)
+	if err != nil {
+		failGetImage(c, err)
+		return
+	}
+
+	writeImage(c, im)
+}
+
+func (h *Handler) GetImageVariant(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	im, err := h.service.GetImageVariant(c.Request.Context(), id, c.Param("variant"))
+	if err != nil {
+		failGetImage(c, err)
+		return
+	}
 
-		zlog.Logger.Error().Err(err).Msg("failed to get image")
-		handlers.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+	writeImage(c, im)
+}
+
+func (h *Handler) GetImageStatus(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	im, err := h.service.GetImageStatus(c.Request.Context(), id)
+	if err != nil {
+		failGetImage(c, err)
 		return
 	}
 
 	handlers.OK(c.Writer, im)
 }
+
+// writeImage sends the raw object. Objects never change once written, so the
+// ETag lets clients revalidate cached copies with If-None-Match.
+func writeImage(c *ginext.Context, im *models.ImageObject) {
+	etag := `"` + im.ETag + `"`
+	header := c.Writer.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", cacheControl)
+	header.Set("Last-Modified", im.LastModified.UTC().Format(http.TimeFormat))
+	if im.ETag != "" && c.GetHeader("If-None-Match") == etag {
+		c.Writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Length", strconv.FormatInt(im.Size, 10))
+	handlers.Data(c.Writer, im.ContentType, im.Data)
+}
+
+func parseID(c *ginext.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Msg("id is not a proper uuid or empty parameter")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("non-empty and proper id required"))
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+func failGetImage(c *ginext.Context, err error) {
+	var failedErr *images.FailedError
+	if errors.As(err, &failedErr) {
+		zlog.Logger.Warn().Err(err).Msg("image processing failed")
+		handlers.JSON(c.Writer, http.StatusUnprocessableEntity, handlers.Failed{
+			Message: "image processing failed",
+			Status:  imageStatusFailed,
+			Error: handlers.FailedError{
+				Code:    failedErr.Code,
+				Message: failedErr.Message,
+			},
+		})
+		return
+	} else if errors.Is(err, images.ErrImageNotFound) {
+		zlog.Logger.Warn().Err(err).Msg("image not found")
+		handlers.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("image not found"))
+		return
+	} else if errors.Is(err, service.ErrVariantNotFound) {
+		zlog.Logger.Warn().Err(err).Msg("variant not found")
+		handlers.Fail(c.Writer, http.StatusNotFound, fmt.Errorf("variant not found"))
+		return
+	} else if errors.Is(err, images.ErrImageInProcess) {
+		zlog.Logger.Warn().Err(err).Msg("image in process")
+		handlers.Fail(c.Writer, http.StatusServiceUnavailable, fmt.Errorf("image in process"))
+		return
+	}
+
+	zlog.Logger.Error().Err(err).Msg("failed to get image")
+	handlers.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+}
@@ -6,22 +6,31 @@ import (
 	"github.com/avraam311/image-processor/internal/models"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
 type Service interface {
-	UploadImage(context.Context, *models.Image) (uint, error)
-	GetProcessedImage(context.Context, uint) ([]byte, error)
-	DeleteImage(context.Context, uint) error
+	UploadImage(context.Context, *models.Image) (*models.UploadResult, error)
+	GetProcessedImage(context.Context, uuid.UUID) (*models.ImageObject, error)
+	GetOriginalImage(context.Context, uuid.UUID) (*models.ImageObject, error)
+	GetImageVariant(context.Context, uuid.UUID, string) (*models.ImageObject, error)
+	GetImageStatus(context.Context, uuid.UUID) (*models.ImageRecord, error)
+	ListImages(context.Context, *models.ImageFilter) (*models.ImagePage, error)
+	DeleteImage(context.Context, uuid.UUID) error
 }
 
+// Handler serves the image routes. Pipeline steps producing more than
+// maxPixels pixels, 0 is unlimited, are rejected.
 type Handler struct {
 	service   Service
 	validator *validator.Validate
+	maxPixels int64
 }
 
-func NewHandler(service Service, validator *validator.Validate) *Handler {
+func NewHandler(service Service, validator *validator.Validate, maxPixels int64) *Handler {
 	return &Handler{
 		service:   service,
 		validator: validator,
+		maxPixels: maxPixels,
 	}
 }
@@ -0,0 +1,510 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/api/handlers"
+	"github.com/avraam311/image-processor/internal/models"
+	service "github.com/avraam311/image-processor/internal/service/images"
+
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMaxPixels = 1000000
+
+var (
+	jpegSource = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00body")
+	pngSource  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dbody")
+	webpSource = []byte("RIFF\x24\x00\x00\x00WEBPVP8 body")
+)
+
+// fakeService records what reaches it, the handler tests only check what is
+// rejected before.
+type fakeService struct {
+	uploaded *models.Image
+	filter   *models.ImageFilter
+	listErr  error
+	// object is returned by the getters, which record what was asked for.
+	object  *models.ImageObject
+	getErr  error
+	got     string
+	variant string
+	record  *models.ImageRecord
+}
+
+func (s *fakeService) UploadImage(ctx context.Context, im *models.Image) (*models.UploadResult, error) {
+	s.uploaded = im
+	return &models.UploadResult{ID: uuid.Must(uuid.NewV7()), StatusCode: http.StatusCreated}, nil
+}
+
+func (s *fakeService) GetProcessedImage(ctx context.Context, id uuid.UUID) (*models.ImageObject, error) {
+	s.got = "processed"
+	return s.object, s.getErr
+}
+
+func (s *fakeService) GetOriginalImage(ctx context.Context, id uuid.UUID) (*models.ImageObject, error) {
+	s.got = "original"
+	return s.object, s.getErr
+}
+
+func (s *fakeService) GetImageVariant(ctx context.Context, id uuid.UUID, variant string) (*models.ImageObject, error) {
+	s.got, s.variant = "variant", variant
+	return s.object, s.getErr
+}
+
+func (s *fakeService) GetImageStatus(ctx context.Context, id uuid.UUID) (*models.ImageRecord, error) {
+	return s.record, s.getErr
+}
+
+func (s *fakeService) ListImages(ctx context.Context, filter *models.ImageFilter) (*models.ImagePage, error) {
+	s.filter = filter
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+	return &models.ImagePage{}, nil
+}
+
+func (s *fakeService) DeleteImage(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+func newTestRouter(service *fakeService) *ginext.Engine {
+	h := NewHandler(service, validator.New(), testMaxPixels)
+	e := ginext.New("release")
+	e.POST("/upload", h.UploadImage)
+	e.GET("/images", h.ListImages)
+	e.GET("/image/:id", h.GetProcessedImage)
+	e.GET("/image/:id/status", h.GetImageStatus)
+	e.GET("/image/:id/original", h.GetOriginalImage)
+	e.GET("/image/:id/variants/:variant", h.GetImageVariant)
+	return e
+}
+
+func multipartUpload(t *testing.T, image []byte, fields map[string]string) *http.Request {
+	t.Helper()
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	if image != nil {
+		part, err := mw.CreateFormFile("image", "cat")
+		require.NoError(t, err)
+		_, err = part.Write(image)
+		require.NoError(t, err)
+	}
+	for name, value := range fields {
+		require.NoError(t, mw.WriteField(name, value))
+	}
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadImage_BadRequest(t *testing.T) {
+	tests := []struct {
+		name            string
+		image           []byte
+		noImage         bool
+		fields          map[string]string
+		expectedMessage string
+	}{
+		{
+			name:            "missing image",
+			noImage:         true,
+			fields:          map[string]string{"processing": "thumbnail"},
+			expectedMessage: "image file required",
+		},
+		{
+			name:            "width not an integer",
+			fields:          map[string]string{"processing": "resize", "width": "wide"},
+			expectedMessage: "validation error: width must be an integer",
+		},
+		{
+			name:            "height not an integer",
+			fields:          map[string]string{"processing": "resize", "height": "tall"},
+			expectedMessage: "validation error: height must be an integer",
+		},
+		{
+			name:            "quality not an integer",
+			fields:          map[string]string{"processing": "thumbnail", "quality": "high"},
+			expectedMessage: "validation error: quality must be an integer",
+		},
+		{
+			name:            "colors not an integer",
+			fields:          map[string]string{"processing": "thumbnail", "colors": "many"},
+			expectedMessage: "validation error: colors must be an integer",
+		},
+		{
+			name:            "pipeline not json",
+			fields:          map[string]string{"pipeline": "{"},
+			expectedMessage: "validation error: pipeline must be a JSON array of operations",
+		},
+		{
+			name:            "no processing",
+			fields:          map[string]string{},
+			expectedMessage: "validation error: " + errProcessingRequired.Error(),
+		},
+		{
+			name:            "processing and pipeline",
+			fields:          map[string]string{"processing": "thumbnail", "pipeline": `[{"op":"grayscale"}]`},
+			expectedMessage: "validation error: " + errProcessingAndPipe.Error(),
+		},
+		{
+			name:            "params without resize",
+			fields:          map[string]string{"processing": "thumbnail", "width": "100"},
+			expectedMessage: "validation error: " + errParamsNotSupported.Error(),
+		},
+		{
+			name:            "output and encode step",
+			fields:          map[string]string{"pipeline": `[{"op":"encode","format":"png"}]`, "format": "jpeg"},
+			expectedMessage: "validation error: " + errOutputAndEncode.Error(),
+		},
+		{
+			name:            "auto-orient not first",
+			fields:          map[string]string{"pipeline": `[{"op":"grayscale"},{"op":"auto-orient"}]`},
+			expectedMessage: "validation error: step 2: auto-orient must be the first step",
+		},
+		{
+			name:            "crop without height",
+			fields:          map[string]string{"pipeline": `[{"op":"crop","width":10}]`},
+			expectedMessage: "validation error: step 1: crop needs both width and height",
+		},
+		{
+			name:            "resize without size",
+			fields:          map[string]string{"pipeline": `[{"op":"resize"}]`},
+			expectedMessage: "validation error: step 1: resize needs width or height",
+		},
+		{
+			name:            "fill without height",
+			fields:          map[string]string{"processing": "resize", "width": "100", "fit": "fill"},
+			expectedMessage: "validation error: step 1: fill and pad need both width and height",
+		},
+		{
+			name:            "resize over max pixels",
+			fields:          map[string]string{"processing": "resize", "width": "2000", "height": "1000"},
+			expectedMessage: "validation error: step 1: resize to 2000x1000 exceeds 1000000 pixels",
+		},
+		{
+			name:            "pad over max pixels",
+			fields:          map[string]string{"pipeline": `[{"op":"resize","width":1001,"height":1000,"fit":"pad"}]`},
+			expectedMessage: "validation error: step 1: resize to 1001x1000 exceeds 1000000 pixels",
+		},
+		{
+			name:            "thumbnail over max pixels",
+			fields:          map[string]string{"pipeline": `[{"op":"grayscale"},{"op":"thumbnail","width":10000,"height":10000}]`},
+			expectedMessage: "validation error: step 2: thumbnail to 10000x10000 exceeds 1000000 pixels",
+		},
+		{
+			name:            "invalid output background",
+			fields:          map[string]string{"processing": "thumbnail", "format": "jpeg", "background": "black"},
+			expectedMessage: "validation error: Key: 'Image.Pipeline[1].ProcessingParams.Background' Error:Field validation for 'Background' failed on the 'hexcolor' tag\nKey: 'Image.Output.Background' Error:Field validation for 'Background' failed on the 'hexcolor' tag",
+		},
+		{
+			name:            "thumbnail without height",
+			fields:          map[string]string{"pipeline": `[{"op":"thumbnail","width":50}]`},
+			expectedMessage: "validation error: step 1: thumbnail needs both width and height or neither",
+		},
+		{
+			name:            "blur without sigma",
+			fields:          map[string]string{"pipeline": `[{"op":"blur"}]`},
+			expectedMessage: "validation error: step 1: blur needs a positive sigma",
+		},
+		{
+			name:            "encode not last",
+			fields:          map[string]string{"pipeline": `[{"op":"encode","format":"png"},{"op":"grayscale"}]`},
+			expectedMessage: "validation error: step 1: encode must be the last step",
+		},
+		{
+			name:            "quality for a png source",
+			image:           pngSource,
+			fields:          map[string]string{"processing": "thumbnail", "quality": "80"},
+			expectedMessage: "validation error: step 2: quality is supported only for jpeg",
+		},
+		{
+			name:            "quality for png output",
+			fields:          map[string]string{"processing": "thumbnail", "format": "png", "quality": "80"},
+			expectedMessage: "validation error: step 2: quality is supported only for jpeg",
+		},
+		{
+			name:            "compression for a jpeg source",
+			fields:          map[string]string{"processing": "thumbnail", "compression": "best"},
+			expectedMessage: "validation error: step 2: compression is supported only for png",
+		},
+		{
+			name:            "colors for a webp source",
+			image:           webpSource,
+			fields:          map[string]string{"processing": "thumbnail", "colors": "16"},
+			expectedMessage: "validation error: step 2: colors is supported only for gif",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{}
+			image := tt.image
+			if image == nil && !tt.noImage {
+				image = jpegSource
+			}
+
+			w := httptest.NewRecorder()
+			newTestRouter(service).ServeHTTP(w, multipartUpload(t, image, tt.fields))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var body handlers.Error
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.expectedMessage, body.Message)
+			assert.Nil(t, service.uploaded)
+		})
+	}
+}
+
+func TestUploadImage_OutputOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		image  []byte
+		fields map[string]string
+	}{
+		{name: "quality for a jpeg source", image: jpegSource, fields: map[string]string{"processing": "thumbnail", "quality": "80"}},
+		{name: "quality for a webp source", image: webpSource, fields: map[string]string{"processing": "thumbnail", "quality": "80"}},
+		{name: "compression for a png source", image: pngSource, fields: map[string]string{"processing": "thumbnail", "compression": "best"}},
+		{name: "quality for jpeg output", image: pngSource, fields: map[string]string{"processing": "thumbnail", "format": "jpeg", "quality": "80"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{}
+
+			w := httptest.NewRecorder()
+			newTestRouter(service).ServeHTTP(w, multipartUpload(t, tt.image, tt.fields))
+
+			require.Equal(t, http.StatusCreated, w.Code)
+			require.NotNil(t, service.uploaded)
+			require.Len(t, service.uploaded.Pipeline, 2)
+			assert.Equal(t, opEncode, service.uploaded.Pipeline[1].Op)
+			// The service reads the upload from the start.
+			header := make([]byte, 4)
+			_, err := service.uploaded.File.Read(header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.image[:4], header)
+		})
+	}
+}
+
+func TestUploadImage_Background(t *testing.T) {
+	jsonUpload := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+	image := base64.StdEncoding.EncodeToString(pngSource)
+
+	tests := []struct {
+		name     string
+		req      func(t *testing.T) *http.Request
+		expected []models.Operation
+	}{
+		{
+			name: "form output",
+			req: func(t *testing.T) *http.Request {
+				return multipartUpload(t, pngSource, map[string]string{"processing": "thumbnail", "format": "jpeg", "background": "#000000"})
+			},
+			expected: []models.Operation{
+				{Op: "thumbnail"},
+				{Op: opEncode, EncodeParams: models.EncodeParams{Format: "jpeg"}, ProcessingParams: models.ProcessingParams{Background: "#000000"}},
+			},
+		},
+		{
+			name: "form pad and output",
+			req: func(t *testing.T) *http.Request {
+				return multipartUpload(t, pngSource, map[string]string{"processing": "resize", "width": "10", "height": "10", "fit": "pad", "background": "#000000"})
+			},
+			expected: []models.Operation{
+				{Op: opResize, ProcessingParams: models.ProcessingParams{Width: 10, Height: 10, Fit: "pad", Background: "#000000"}},
+				{Op: opEncode, ProcessingParams: models.ProcessingParams{Background: "#000000"}},
+			},
+		},
+		{
+			name: "json output",
+			req: func(t *testing.T) *http.Request {
+				return jsonUpload(`{"image":"` + image + `","processing":"thumbnail","output":{"format":"jpeg","background":"#fff"}}`)
+			},
+			expected: []models.Operation{
+				{Op: "thumbnail"},
+				{Op: opEncode, EncodeParams: models.EncodeParams{Format: "jpeg"}, ProcessingParams: models.ProcessingParams{Background: "#fff"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{}
+
+			w := httptest.NewRecorder()
+			newTestRouter(service).ServeHTTP(w, tt.req(t))
+
+			require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+			require.NotNil(t, service.uploaded)
+			assert.Equal(t, tt.expected, service.uploaded.Pipeline)
+		})
+	}
+}
+
+func TestListImages_BadRequest(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		expectedMessage string
+	}{
+		{name: "limit not an integer", query: "limit=ten", expectedMessage: "validation error: limit must be an integer"},
+		{name: "created_from not a timestamp", query: "created_from=yesterday", expectedMessage: "validation error: created_from must be an RFC 3339 timestamp"},
+		{name: "created_to not a timestamp", query: "created_to=2025-12-01", expectedMessage: "validation error: created_to must be an RFC 3339 timestamp"},
+		{name: "limit out of range", query: "limit=0", expectedMessage: "validation error: "},
+		{name: "unknown sort", query: "sort=up", expectedMessage: "validation error: "},
+		{name: "unknown status", query: "status=done", expectedMessage: "validation error: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{}
+
+			w := httptest.NewRecorder()
+			newTestRouter(service).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/images?"+tt.query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var body handlers.Error
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Contains(t, body.Message, tt.expectedMessage)
+			assert.Nil(t, service.filter)
+		})
+	}
+}
+
+func TestListImages_Cursor(t *testing.T) {
+	tests := []struct {
+		name            string
+		listErr         error
+		expectedCode    int
+		expectedMessage string
+	}{
+		{name: "passed to the service", expectedCode: http.StatusOK},
+		{name: "malformed", listErr: fmt.Errorf("service/images - %w", service.ErrInvalidCursor), expectedCode: http.StatusBadRequest, expectedMessage: "invalid cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{listErr: tt.listErr}
+
+			w := httptest.NewRecorder()
+			newTestRouter(svc).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/images?cursor=abc&limit=5", nil))
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			require.NotNil(t, svc.filter)
+			assert.Equal(t, "abc", svc.filter.Cursor)
+			assert.Equal(t, 5, svc.filter.Limit)
+			if tt.expectedMessage != "" {
+				var body handlers.Error
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tt.expectedMessage, body.Message)
+			}
+		})
+	}
+}
+
+func TestGetImage(t *testing.T) {
+	id := uuid.Must(uuid.NewV7())
+	lastModified := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	object := &models.ImageObject{
+		Data:         []byte("png bytes"),
+		ContentType:  "image/png",
+		Size:         9,
+		ETag:         "abc123",
+		LastModified: lastModified,
+	}
+
+	tests := []struct {
+		name            string
+		path            string
+		ifNoneMatch     string
+		getErr          error
+		expectedCode    int
+		expectedGot     string
+		expectedVariant string
+	}{
+		{name: "processed", path: "/image/" + id.String(), expectedCode: http.StatusOK, expectedGot: "processed"},
+		{name: "original", path: "/image/" + id.String() + "/original", expectedCode: http.StatusOK, expectedGot: "original"},
+		{name: "variant", path: "/image/" + id.String() + "/variants/small", expectedCode: http.StatusOK, expectedGot: "variant", expectedVariant: "small"},
+		{name: "etag matches", path: "/image/" + id.String(), ifNoneMatch: `"abc123"`, expectedCode: http.StatusNotModified, expectedGot: "processed"},
+		{name: "etag differs", path: "/image/" + id.String(), ifNoneMatch: `"other"`, expectedCode: http.StatusOK, expectedGot: "processed"},
+		{name: "unquoted etag", path: "/image/" + id.String(), ifNoneMatch: "abc123", expectedCode: http.StatusOK, expectedGot: "processed"},
+		{name: "variant not found", path: "/image/" + id.String() + "/variants/big", getErr: service.ErrVariantNotFound, expectedCode: http.StatusNotFound, expectedGot: "variant", expectedVariant: "big"},
+		{name: "invalid id", path: "/image/42", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{object: object, getErr: tt.getErr}
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+
+			w := httptest.NewRecorder()
+			newTestRouter(svc).ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedGot, svc.got)
+			assert.Equal(t, tt.expectedVariant, svc.variant)
+			header := w.Header()
+			switch tt.expectedCode {
+			case http.StatusOK:
+				assert.Equal(t, "image/png", header.Get("Content-Type"))
+				assert.Equal(t, "9", header.Get("Content-Length"))
+				assert.Equal(t, object.Data, w.Body.Bytes())
+			case http.StatusNotModified:
+				assert.Empty(t, header.Get("Content-Length"))
+				assert.Empty(t, w.Body.Bytes())
+			default:
+				assert.Empty(t, header.Get("ETag"))
+				return
+			}
+			assert.Equal(t, `"abc123"`, header.Get("ETag"))
+			assert.Equal(t, cacheControl, header.Get("Cache-Control"))
+			assert.Equal(t, "Mon, 01 Dec 2025 12:00:00 GMT", header.Get("Last-Modified"))
+		})
+	}
+}
+
+func TestGetImageStatus_HidesHashes(t *testing.T) {
+	id := uuid.Must(uuid.NewV7())
+	svc := &fakeService{record: &models.ImageRecord{
+		ID:          id,
+		Status:      "processed",
+		ContentHash: strings.Repeat("a", 64),
+		SpecHash:    strings.Repeat("b", 64),
+		ObjectID:    uuid.Must(uuid.NewV7()),
+	}}
+
+	w := httptest.NewRecorder()
+	newTestRouter(svc).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/image/"+id.String()+"/status", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), id.String())
+	assert.NotContains(t, w.Body.String(), "content_hash")
+	assert.NotContains(t, w.Body.String(), strings.Repeat("a", 64))
+	assert.NotContains(t, w.Body.String(), strings.Repeat("b", 64))
+}
@@ -0,0 +1,97 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/api/handlers"
+	"github.com/avraam311/image-processor/internal/models"
+	service "github.com/avraam311/image-processor/internal/service/images"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+)
+
+const (
+	defaultListLimit = 20
+	defaultListSort  = "desc"
+)
+
+// ListImages pages through images newest first by default. Query parameters:
+// status, processing, source_format, created_from and created_to (RFC 3339),
+// sort (asc or desc), limit (1-100) and cursor from the previous page.
+func (h *Handler) ListImages(c *ginext.Context) {
+	filter, err := filterFromQuery(c.Request.URL.Query())
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Msg("failed to parse list query")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+	if err := h.validator.Struct(filter); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("failed to validate list query")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	page, err := h.service.ListImages(c.Request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			zlog.Logger.Warn().Err(err).Msg("invalid cursor")
+			handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid cursor"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Msg("failed to list images")
+		handlers.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	handlers.OK(c.Writer, page)
+}
+
+func filterFromQuery(query url.Values) (*models.ImageFilter, error) {
+	filter := models.ImageFilter{
+		Status:       query.Get("status"),
+		Processing:   query.Get("processing"),
+		SourceFormat: query.Get("source_format"),
+		Sort:         query.Get("sort"),
+		Limit:        defaultListLimit,
+		Cursor:       query.Get("cursor"),
+	}
+	if filter.Sort == "" {
+		filter.Sort = defaultListSort
+	}
+
+	var err error
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, fmt.Errorf("limit must be an integer")
+		}
+	}
+	if filter.CreatedFrom, err = timeFromQuery(query, "created_from"); err != nil {
+		return nil, err
+	}
+	if filter.CreatedTo, err = timeFromQuery(query, "created_to"); err != nil {
+		return nil, err
+	}
+
+	return &filter, nil
+}
+
+func timeFromQuery(query url.Values, key string) (*time.Time, error) {
+	value := query.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", key)
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,244 @@
+package images
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/avraam311/image-processor/internal/imageformat"
+	"github.com/avraam311/image-processor/internal/models"
+)
+
+const (
+	opAutoOrient = "auto-orient"
+	opCrop       = "crop"
+	opResize     = "resize"
+	opThumbnail  = "thumbnail"
+	opSharpen    = "sharpen"
+	opBlur       = "blur"
+	opEncode     = "encode"
+	fitFill      = "fill"
+	fitPad       = "pad"
+	formatJPEG   = "jpeg"
+	formatPNG    = "png"
+	formatGIF    = "gif"
+
+	defaultResizeWidth  = 800
+	defaultResizeHeight = 600
+)
+
+var (
+	errProcessingRequired = errors.New("processing or pipeline required")
+	errProcessingAndPipe  = errors.New("processing and pipeline are mutually exclusive")
+	errParamsNotSupported = errors.New("params are supported only for resize")
+	errOutputAndEncode    = errors.New("output and an encode step are mutually exclusive")
+)
+
+// paramsFromForm builds resize parameters from the width, height, fit,
+// filter and background form fields. It returns nil when none but background
+// are set, background alone is an output option.
+func paramsFromForm(r *http.Request) (*models.ProcessingParams, error) {
+	width, height := r.FormValue("width"), r.FormValue("height")
+	params := models.ProcessingParams{
+		Fit:        r.FormValue("fit"),
+		Filter:     r.FormValue("filter"),
+		Background: r.FormValue("background"),
+	}
+	if width == "" && height == "" && params.Fit == "" && params.Filter == "" {
+		return nil, nil
+	}
+
+	var err error
+	if width != "" {
+		if params.Width, err = strconv.Atoi(width); err != nil {
+			return nil, fmt.Errorf("width must be an integer")
+		}
+	}
+	if height != "" {
+		if params.Height, err = strconv.Atoi(height); err != nil {
+			return nil, fmt.Errorf("height must be an integer")
+		}
+	}
+
+	return &params, nil
+}
+
+// outputFromForm builds output options from the format, quality,
+// compression, colors and background form fields. It returns nil when none
+// are set.
+func outputFromForm(r *http.Request) (*models.OutputParams, error) {
+	quality, colors := r.FormValue("quality"), r.FormValue("colors")
+	output := models.OutputParams{
+		EncodeParams: models.EncodeParams{
+			Format:      r.FormValue("format"),
+			Compression: r.FormValue("compression"),
+		},
+		Background: r.FormValue("background"),
+	}
+	if quality == "" && colors == "" && output.Format == "" && output.Compression == "" && output.Background == "" {
+		return nil, nil
+	}
+
+	var err error
+	if quality != "" {
+		if output.Quality, err = strconv.Atoi(quality); err != nil {
+			return nil, fmt.Errorf("quality must be an integer")
+		}
+	}
+	if colors != "" {
+		if output.Colors, err = strconv.Atoi(colors); err != nil {
+			return nil, fmt.Errorf("colors must be an integer")
+		}
+	}
+
+	return &output, nil
+}
+
+// pipelineFromForm decodes the JSON encoded "pipeline" form field.
+func pipelineFromForm(r *http.Request) ([]models.Operation, error) {
+	value := r.FormValue("pipeline")
+	if value == "" {
+		return nil, nil
+	}
+
+	var pipeline []models.Operation
+	if err := json.Unmarshal([]byte(value), &pipeline); err != nil {
+		return nil, fmt.Errorf("pipeline must be a JSON array of operations")
+	}
+
+	return pipeline, nil
+}
+
+// buildPipeline turns the single processing type of older clients into a
+// one step pipeline and the output options into a final encode step, so the
+// worker only ever deals with pipelines.
+func buildPipeline(im *models.Image) error {
+	switch {
+	case im.Processing == "" && len(im.Pipeline) == 0:
+		return errProcessingRequired
+	case im.Processing != "" && len(im.Pipeline) > 0:
+		return errProcessingAndPipe
+	case len(im.Pipeline) > 0:
+		return appendOutput(im)
+	}
+
+	if im.Params != nil && im.Processing != opResize {
+		return errParamsNotSupported
+	}
+	op := models.Operation{Op: im.Processing}
+	if im.Params != nil {
+		op.ProcessingParams = *im.Params
+	} else if im.Processing == opResize {
+		op.Width, op.Height = defaultResizeWidth, defaultResizeHeight
+	}
+	im.Pipeline = []models.Operation{op}
+
+	return appendOutput(im)
+}
+
+func appendOutput(im *models.Image) error {
+	if im.Output == nil {
+		return nil
+	}
+	if im.Pipeline[len(im.Pipeline)-1].Op == opEncode {
+		return errOutputAndEncode
+	}
+	op := models.Operation{Op: opEncode, EncodeParams: im.Output.EncodeParams}
+	op.Background = im.Output.Background
+	im.Pipeline = append(im.Pipeline, op)
+
+	return nil
+}
+
+// checkPipeline validates the combinations the struct tags cannot express.
+// Encoder options are checked against the format the encode step writes,
+// which without an explicit format is the format of the source. Resize and
+// thumbnail targets over maxPixels, 0 is unlimited, are rejected like
+// oversized uploads.
+func checkPipeline(pipeline []models.Operation, sourceFormat string, maxPixels int64) error {
+	for i, op := range pipeline {
+		if (op.Op == opResize || op.Op == opThumbnail) && maxPixels > 0 && int64(op.Width)*int64(op.Height) > maxPixels {
+			return fmt.Errorf("step %d: %s to %dx%d exceeds %d pixels", i+1, op.Op, op.Width, op.Height, maxPixels)
+		}
+
+		switch op.Op {
+		case opAutoOrient:
+			if i != 0 {
+				return fmt.Errorf("step %d: auto-orient must be the first step", i+1)
+			}
+		case opCrop:
+			if op.Width == 0 || op.Height == 0 {
+				return fmt.Errorf("step %d: crop needs both width and height", i+1)
+			}
+		case opResize:
+			if op.Width == 0 && op.Height == 0 {
+				return fmt.Errorf("step %d: resize needs width or height", i+1)
+			}
+			if (op.Fit == fitFill || op.Fit == fitPad) && (op.Width == 0 || op.Height == 0) {
+				return fmt.Errorf("step %d: fill and pad need both width and height", i+1)
+			}
+		case opThumbnail:
+			if (op.Width == 0) != (op.Height == 0) {
+				return fmt.Errorf("step %d: thumbnail needs both width and height or neither", i+1)
+			}
+		case opSharpen, opBlur:
+			if op.Sigma == 0 {
+				return fmt.Errorf("step %d: %s needs a positive sigma", i+1, op.Op)
+			}
+		case opEncode:
+			if i != len(pipeline)-1 {
+				return fmt.Errorf("step %d: encode must be the last step", i+1)
+			}
+			format := outputFormat(op.Format, sourceFormat)
+			if op.Quality != 0 && format != "" && format != formatJPEG {
+				return fmt.Errorf("step %d: quality is supported only for jpeg", i+1)
+			}
+			if op.Compression != "" && format != "" && format != formatPNG {
+				return fmt.Errorf("step %d: compression is supported only for png", i+1)
+			}
+			if op.Colors != 0 && format != formatGIF {
+				return fmt.Errorf("step %d: colors is supported only for gif", i+1)
+			}
+		}
+	}
+
+	return nil
+}
+
+// outputFormat is the format an encode step writes: the requested one, else
+// the source format. WebP sources become PNG or JPEG depending on their
+// transparency, so the format is "" for them as for unknown sources.
+func outputFormat(format, sourceFormat string) string {
+	if format != "" {
+		return format
+	}
+	if sourceFormat == imageformat.WEBP {
+		return ""
+	}
+
+	return sourceFormat
+}
+
+// sniffSource detects the format of the upload and rewinds it. It returns ""
+// for unknown formats, which the service rejects, and uploads that can't seek.
+func sniffSource(file io.Reader) string {
+	seeker, ok := file.(io.ReadSeeker)
+	if !ok {
+		return ""
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return ""
+	}
+	header := make([]byte, imageformat.SniffLen)
+	n, _ := io.ReadFull(seeker, header)
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return ""
+	}
+	format, _ := imageformat.Detect(header[:n])
+
+	return format
+}
@@ -1,37 +1,184 @@
 package images
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/avraam311/image-processor/internal/api/handlers"
 	"github.com/avraam311/image-processor/internal/models"
+	service "github.com/avraam311/image-processor/internal/service/images"
 
 	"github.com/wb-go/wbf/ginext"
 	"github.com/wb-go/wbf/zlog"
 )
 
+const (
+	maxUploadSize       = 32 << 20
+	maxUploadMemory     = 1 << 20
+	multipartFormPrefix = "multipart/form-data"
+	idempotencyHeader   = "Idempotency-Key"
+)
+
 func (h *Handler) UploadImage(c *ginext.Context) {
-	var im models.Image
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+
+	if strings.HasPrefix(c.ContentType(), multipartFormPrefix) {
+		h.uploadMultipart(c)
+		return
+	}
+
+	h.uploadJSON(c)
+}
+
+// uploadMultipart accepts an "image" file part and a "processing" field.
+// Parts bigger than maxUploadMemory are spooled to a temporary file by
+// net/http, so the picture is streamed into storage without being held in memory.
+func (h *Handler) uploadMultipart(c *ginext.Context) {
+	if err := c.Request.ParseMultipartForm(maxUploadMemory); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to parse multipart form")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid multipart form: %s", err.Error()))
+		return
+	}
+	defer func() {
+		if err := c.Request.MultipartForm.RemoveAll(); err != nil {
+			zlog.Logger.Warn().Err(err).Msg("failed to remove multipart temporary files")
+		}
+	}()
+
+	file, header, err := c.Request.FormFile("image")
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to get image from multipart form")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("image file required"))
+		return
+	}
+	defer file.Close()
 
-	if err := json.NewDecoder(c.Request.Body).Decode(&im); err != nil {
+	params, err := paramsFromForm(c.Request)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to parse processing params")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	pipeline, err := pipelineFromForm(c.Request)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to parse processing pipeline")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	output, err := outputFromForm(c.Request)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to parse output options")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	im := models.Image{
+		File:       file,
+		Size:       header.Size,
+		FileName:   header.Filename,
+		Processing: c.Request.FormValue("processing"),
+		Params:     params,
+		Pipeline:   pipeline,
+		Output:     output,
+		Variant:    c.Request.FormValue("variant"),
+	}
+
+	h.upload(c, &im)
+}
+
+func (h *Handler) uploadJSON(c *ginext.Context) {
+	var imJSON models.ImageJSON
+
+	if err := json.NewDecoder(c.Request.Body).Decode(&imJSON); err != nil {
 		zlog.Logger.Error().Err(err).Msg("failed to decode request body")
 		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid request body: %s", err.Error()))
 		return
 	}
 
+	if err := h.validator.Struct(imJSON); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request body")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	im := models.Image{
+		File:       bytes.NewReader(imJSON.Image),
+		Size:       int64(len(imJSON.Image)),
+		Processing: imJSON.Processing,
+		Params:     imJSON.Params,
+		Pipeline:   imJSON.Pipeline,
+		Output:     imJSON.Output,
+		Variant:    imJSON.Variant,
+	}
+
+	h.upload(c, &im)
+}
+
+func (h *Handler) upload(c *ginext.Context, im *models.Image) {
+	im.IdempotencyKey = c.GetHeader(idempotencyHeader)
+	if err := buildPipeline(im); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to build processing pipeline")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
 	if err := h.validator.Struct(im); err != nil {
 		zlog.Logger.Error().Err(err).Msg("failed to validate request body")
 		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
 		return
 	}
+	if err := checkPipeline(im.Pipeline, sniffSource(im.File), h.maxPixels); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate processing pipeline")
+		handlers.Fail(c.Writer, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
 
-	id, err := h.service.UploadImage(c.Request.Context(), &im)
+	res, err := h.service.UploadImage(c.Request.Context(), im)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Interface("image", im).Msg("failed to upload image")
+		var quotaErr *service.QuotaError
+		if errors.As(err, &quotaErr) {
+			zlog.Logger.Warn().Err(err).Msg("upload quota exceeded")
+			header := c.Writer.Header()
+			header.Set("X-Quota", quotaErr.Quota)
+			header.Set("X-Quota-Limit", strconv.FormatInt(quotaErr.Limit, 10))
+			header.Set("X-Quota-Used", strconv.FormatInt(quotaErr.Used, 10))
+			handlers.TooManyRequests(c.Writer, quotaErr.RetryAfter, fmt.Errorf("%s quota exceeded", quotaErr.Quota))
+			return
+		}
+		if errors.Is(err, service.ErrIdempotencyKeyReused) {
+			zlog.Logger.Warn().Err(err).Str("key", im.IdempotencyKey).Msg("idempotency key reused")
+			handlers.Fail(c.Writer, http.StatusConflict, fmt.Errorf("idempotency key already used for a different request"))
+			return
+		}
+		if errors.Is(err, service.ErrIdempotencyKeyInUse) {
+			zlog.Logger.Warn().Err(err).Str("key", im.IdempotencyKey).Msg("idempotency key in use")
+			handlers.Fail(c.Writer, http.StatusConflict, fmt.Errorf("a request with this idempotency key is still in progress"))
+			return
+		}
+		if errors.Is(err, service.ErrImageTooLarge) {
+			zlog.Logger.Warn().Err(err).Msg("image too large")
+			handlers.Fail(c.Writer, http.StatusRequestEntityTooLarge, fmt.Errorf("image has too many pixels"))
+			return
+		}
+		if errors.Is(err, service.ErrUnsupportedFormat) {
+			zlog.Logger.Warn().Err(err).Msg("unsupported image format")
+			handlers.Fail(c.Writer, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported image format, supported formats: jpeg, png, gif, bmp, tiff, webp"))
+			return
+		}
+
+		zlog.Logger.Error().Err(err).Interface("pipeline", im.Pipeline).Msg("failed to upload image")
+		handlers.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
 		return
 	}
 
-	handlers.Created(c.Writer, id)
+	if res.Replayed {
+		c.Writer.Header().Set("Idempotent-Replayed", "true")
+	}
+	handlers.JSON(c.Writer, res.StatusCode, handlers.Success{Result: res.ID})
 }
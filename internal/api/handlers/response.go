@@ -3,6 +3,8 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/wb-go/wbf/zlog"
 )
@@ -15,6 +17,17 @@ type Error struct {
 	Message string `json:"message"`
 }
 
+type Failed struct {
+	Message string      `json:"message"`
+	Status  string      `json:"status"`
+	Error   FailedError `json:"error"`
+}
+
+type FailedError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 func JSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -35,3 +48,21 @@ func Created(w http.ResponseWriter, result interface{}) {
 func Fail(w http.ResponseWriter, status int, err error) {
 	JSON(w, status, Error{Message: err.Error()})
 }
+
+// TooManyRequests rejects a request over a rate limit or quota. Retry-After
+// is sent in whole seconds when retryAfter is known.
+func TooManyRequests(w http.ResponseWriter, retryAfter time.Duration, err error) {
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64((retryAfter+time.Second-1)/time.Second), 10))
+	}
+	Fail(w, http.StatusTooManyRequests, err)
+}
+
+func Data(w http.ResponseWriter, contentType string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(data); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to write response body")
+	}
+}
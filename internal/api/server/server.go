@@ -6,26 +6,75 @@ import (
 	"github.com/wb-go/wbf/ginext"
 
 	"github.com/avraam311/image-processor/internal/api/handlers/images"
+	"github.com/avraam311/image-processor/internal/health"
+	"github.com/avraam311/image-processor/internal/metrics"
 	"github.com/avraam311/image-processor/internal/middlewares"
+	"github.com/avraam311/image-processor/internal/ratelimit"
 )
 
-func NewRouter(ginMode string, handlerIm *images.Handler) *ginext.Engine {
+// NewRouter builds the API router. A nil keys leaves the API open, otherwise
+// every API route requires an active API key. A nil limiter disables rate
+// limiting.
+func NewRouter(ginMode string, handlerIm *images.Handler, checker *health.Checker, keys middlewares.KeyFinder,
+	limiter *ratelimit.Limiter) *ginext.Engine {
 	e := ginext.New(ginMode)
 
 	e.Use(middlewares.CORSMiddleware())
+	e.Use(middlewares.MetricsMiddleware())
 	e.Use(ginext.Logger())
 	e.Use(ginext.Recovery())
 
+	probes(e, checker)
+
 	api := e.Group("/image-processor/api")
+	if limiter != nil {
+		api.Use(middlewares.IPRateLimitMiddleware(limiter, "ip"))
+	}
+	if keys != nil {
+		api.Use(middlewares.AuthMiddleware(keys))
+	}
+	limit := func(route string) ginext.HandlerFunc {
+		if limiter == nil {
+			return func(c *ginext.Context) {}
+		}
+		return middlewares.RateLimitMiddleware(limiter, route)
+	}
 	{
-		api.POST("/upload", handlerIm.UploadImage)
-		api.GET("/image/:id", handlerIm.GetProcessedImage)
-		api.DELETE("/image/:id", handlerIm.DeleteImage)
+		api.POST("/upload", limit("upload"), handlerIm.UploadImage)
+		api.GET("/images", limit("list"), handlerIm.ListImages)
+		api.GET("/image/:id", limit("read"), handlerIm.GetProcessedImage)
+		api.GET("/image/:id/status", limit("status"), handlerIm.GetImageStatus)
+		api.GET("/image/:id/original", limit("read"), handlerIm.GetOriginalImage)
+		api.GET("/image/:id/variants/:variant", limit("read"), handlerIm.GetImageVariant)
+		api.DELETE("/image/:id", limit("delete"), handlerIm.DeleteImage)
 	}
 
 	return e
 }
 
+// NewWorkerRouter serves the probes of a worker running without the API.
+func NewWorkerRouter(ginMode string, checker *health.Checker) *ginext.Engine {
+	e := ginext.New(ginMode)
+	e.Use(ginext.Recovery())
+	probes(e, checker)
+
+	return e
+}
+
+// probes registers the metrics, liveness and readiness endpoints.
+func probes(e *ginext.Engine, checker *health.Checker) {
+	metricsHandler := metrics.Handler()
+	e.GET("/metrics", func(c *ginext.Context) {
+		metricsHandler.ServeHTTP(c.Writer, c.Request)
+	})
+	e.GET("/healthz", func(c *ginext.Context) {
+		health.Live(c.Writer, c.Request)
+	})
+	e.GET("/readyz", func(c *ginext.Context) {
+		checker.Readiness(c.Writer, c.Request)
+	})
+}
+
 func NewServer(addr string, router *ginext.Engine) *http.Server {
 	return &http.Server{
 		Addr:    addr,
@@ -0,0 +1,156 @@
+// Package app wires the API and the worker. Both can run in one process,
+// sharing the database pool, the job queue and the object store.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	handlers "github.com/avraam311/image-processor/internal/api/handlers/images"
+	"github.com/avraam311/image-processor/internal/api/server"
+	"github.com/avraam311/image-processor/internal/health"
+	imageHandlers "github.com/avraam311/image-processor/internal/infra/handlers/images"
+	"github.com/avraam311/image-processor/internal/infra/outbox"
+	"github.com/avraam311/image-processor/internal/infra/queue"
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/infra/worker"
+	"github.com/avraam311/image-processor/internal/middlewares"
+	"github.com/avraam311/image-processor/internal/ratelimit"
+	repository "github.com/avraam311/image-processor/internal/repository/images"
+	"github.com/avraam311/image-processor/internal/repository/keys"
+	service "github.com/avraam311/image-processor/internal/service/images"
+
+	"github.com/wb-go/wbf/config"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	shutdownTimeout = 5 * time.Second
+)
+
+// Options selects the components Run starts.
+type Options struct {
+	API    bool
+	Worker bool
+}
+
+// Run starts the selected components and blocks until ctx is done. It then
+// stops accepting requests, waits for the relay and the workers and only
+// then closes the queues and the database they use.
+func Run(ctx context.Context, cfg *config.Config, opts Options) error {
+	if !opts.API && !opts.Worker {
+		return fmt.Errorf("app.go - nothing to run")
+	}
+
+	db, err := ConnectDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer CloseDB(db)
+
+	jobs, err := queue.New(cfg, db, cfg.GetString("queue.name"), cfg.GetString("kafka.group_id"))
+	if err != nil {
+		return fmt.Errorf("app.go - failed to initialize job queue - %w", err)
+	}
+	defer func() {
+		if err := jobs.Close(); err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to close job queue")
+		}
+	}()
+	store, err := storage.New(cfg)
+	if err != nil {
+		return fmt.Errorf("app.go - failed to initialize object storage - %w", err)
+	}
+	repo := repository.NewRepository(db)
+
+	checker := health.New(cfg.GetDuration("health.timeout"),
+		health.Check{Name: "postgres", Check: db.Master.PingContext},
+		health.Check{Name: "storage", Check: store.Ping},
+		health.Check{Name: "queue", Check: jobs.Ping},
+	)
+
+	var wg sync.WaitGroup
+	var srv *http.Server
+	if opts.Worker {
+		dlq, err := queue.NewDeadLetter(cfg, db, cfg.GetString("queue.dlq_name"), cfg.GetString("kafka.dlq_group_id"))
+		if err != nil {
+			return fmt.Errorf("app.go - failed to initialize dead-letter queue - %w", err)
+		}
+		defer func() {
+			if err := dlq.Close(); err != nil {
+				zlog.Logger.Error().Err(err).Msg("failed to close dead-letter queue")
+			}
+		}()
+
+		work := worker.New(jobs, dlq, cfg, store, imageHandlers.New(cfg.GetInt64("image.max_pixels")), repo)
+		checker.Add(health.Check{Name: "worker", Check: work.Ready})
+		checker.Detail("worker", func() any { return work.Status() })
+		wg.Go(func() {
+			work.Run(ctx)
+		})
+		zlog.Logger.Info().Msg("worker is running")
+
+		// The API router serves metrics and health itself, a worker alone
+		// gets a listener of its own.
+		if !opts.API {
+			srv = server.NewServer(cfg.GetString("worker.port"), server.NewWorkerRouter(cfg.GetString("server.gin_mode"), checker))
+			go func() {
+				if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					zlog.Logger.Fatal().Err(err).Msg("failed to run worker server")
+				}
+			}()
+		}
+	}
+
+	if opts.API {
+		srvc := service.NewService(repo, cfg, store)
+		hand := handlers.NewHandler(srvc, validator.New(), cfg.GetInt64("image.max_pixels"))
+
+		relay := outbox.NewRelay(repo, jobs, cfg, store)
+		wg.Go(func() {
+			relay.Run(ctx)
+		})
+
+		var finder middlewares.KeyFinder
+		if cfg.GetBool("auth.enabled") {
+			finder = keys.NewRepository(db)
+		}
+		limiter, err := ratelimit.FromConfig(cfg)
+		if err != nil {
+			return err
+		}
+		router := server.NewRouter(cfg.GetString("server.gin_mode"), hand, checker, finder, limiter)
+		srv = server.NewServer(cfg.GetString("server.port"), router)
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				zlog.Logger.Fatal().Err(err).Msg("failed to run server")
+			}
+		}()
+		zlog.Logger.Info().Msg("server is running")
+	}
+
+	<-ctx.Done()
+	zlog.Logger.Info().Msg("shutdown signal received")
+
+	if srv != nil {
+		shutdownCtx, shutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer shutdown()
+
+		zlog.Logger.Info().Msg("shutting down")
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to shutdown server")
+		}
+		if errors.Is(shutdownCtx.Err(), context.DeadlineExceeded) {
+			zlog.Logger.Info().Msg("timeout exceeded, forcing shutdown")
+		}
+	}
+	wg.Wait()
+
+	return nil
+}
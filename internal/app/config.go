@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/wb-go/wbf/config"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/zlog"
+)
+
+const (
+	ConfigFilePath           = "config/local.yaml"
+	StandaloneConfigFilePath = "config/standalone.yaml"
+	EnvFilePath              = ".env"
+)
+
+// LoadConfig reads the env file and merges the config files in order, later
+// files override earlier ones and environment variables override them all.
+func LoadConfig(files ...string) (*config.Config, error) {
+	cfg := config.New()
+	if err := cfg.LoadEnvFiles(EnvFilePath); err != nil {
+		return nil, fmt.Errorf("config.go - %w", err)
+	}
+	cfg.EnableEnv("")
+	if err := cfg.LoadConfigFiles(files...); err != nil {
+		return nil, fmt.Errorf("config.go - %w", err)
+	}
+
+	return cfg, nil
+}
+
+func ConnectDB(cfg *config.Config) (*dbpg.DB, error) {
+	opts := &dbpg.Options{
+		MaxOpenConns:    cfg.GetInt("db.max_open_conns"),
+		MaxIdleConns:    cfg.GetInt("db.max_idle_conns"),
+		ConnMaxLifetime: cfg.GetDuration("db.conn_max_lifetime"),
+	}
+	slavesDNSs := []string{}
+	masterDNS := fmt.Sprintf(
+		"postgres://%s:%s@%s:%s/%s?sslmode=%s",
+		cfg.GetString("DB_USER"), cfg.GetString("DB_PASSWORD"),
+		cfg.GetString("DB_HOST"), cfg.GetString("DB_PORT"),
+		cfg.GetString("DB_NAME"), cfg.GetString("DB_SSL_MODE"),
+	)
+	db, err := dbpg.New(masterDNS, slavesDNSs, opts)
+	if err != nil {
+		return nil, fmt.Errorf("config.go - failed to connect to database - %w", err)
+	}
+
+	return db, nil
+}
+
+func CloseDB(db *dbpg.DB) {
+	if err := db.Master.Close(); err != nil {
+		zlog.Logger.Printf("failed to close master DB: %v", err)
+	}
+	for i, s := range db.Slaves {
+		if err := s.Close(); err != nil {
+			zlog.Logger.Printf("failed to close slave DB %d: %v", i, err)
+		}
+	}
+}
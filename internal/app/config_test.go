@@ -0,0 +1,45 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		files           []string
+		expectedQueue   string
+		expectedStorage string
+	}{
+		{name: "separate processes", files: []string{ConfigFilePath}, expectedQueue: "kafka", expectedStorage: "minio"},
+		{name: "single binary", files: []string{ConfigFilePath, StandaloneConfigFilePath}, expectedQueue: "memory", expectedStorage: "local"},
+	}
+
+	root, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "config"), 0o755))
+	for _, file := range []string{ConfigFilePath, StandaloneConfigFilePath} {
+		data, err := os.ReadFile(filepath.Join(root, file))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), data, 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, EnvFilePath), nil, 0o644))
+	t.Chdir(dir)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(tt.files...)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedQueue, cfg.GetString("queue.backend"))
+			assert.Equal(t, tt.expectedStorage, cfg.GetString("storage.backend"))
+			assert.Equal(t, "images", cfg.GetString("queue.name"))
+		})
+	}
+}
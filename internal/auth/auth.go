@@ -0,0 +1,51 @@
+// Package auth generates API keys and carries the authenticated key through
+// the request context.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	keyPrefix  = "ip_"
+	keyBytes   = 32
+	prefixSize = len(keyPrefix) + 8
+)
+
+type ownerKey struct{}
+
+// GenerateKey returns a new random key, the hash it is stored by and the
+// prefix shown in listings. The key itself is never stored.
+func GenerateKey() (key, hash, prefix string, err error) {
+	buf := make([]byte, keyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", fmt.Errorf("auth.go - failed to generate key - %w", err)
+	}
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	return key, HashKey(key), key[:prefixSize], nil
+}
+
+// HashKey is the hex SHA-256 of key. Keys are random, so a plain hash is
+// enough to make a leaked table useless.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// WithOwner returns ctx carrying the id of the authenticated API key.
+func WithOwner(ctx context.Context, keyID uint) context.Context {
+	return context.WithValue(ctx, ownerKey{}, keyID)
+}
+
+// Owner returns the id of the authenticated API key. It reports false for
+// requests that were not authenticated, i.e. with authentication disabled.
+func Owner(ctx context.Context) (uint, bool) {
+	keyID, ok := ctx.Value(ownerKey{}).(uint)
+	return keyID, ok
+}
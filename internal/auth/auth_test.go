@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateKey(t *testing.T) {
+	key, hash, prefix, err := GenerateKey()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(key, "ip_"))
+	assert.Len(t, key, 3+43)
+	assert.Equal(t, HashKey(key), hash)
+	assert.Len(t, hash, 64)
+	assert.True(t, strings.HasPrefix(key, prefix))
+	assert.Len(t, prefix, 11)
+
+	other, _, _, err := GenerateKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestOwner(t *testing.T) {
+	_, ok := Owner(context.Background())
+	assert.False(t, ok)
+
+	keyID, ok := Owner(WithOwner(context.Background(), 7))
+	assert.True(t, ok)
+	assert.Equal(t, uint(7), keyID)
+}
@@ -0,0 +1,114 @@
+// Package health serves liveness and readiness. Liveness only tells that
+// the process answers, readiness runs the dependency checks.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+// Check reports whether one dependency is usable.
+type Check struct {
+	Name  string
+	Check func(context.Context) error
+}
+
+// Report is the readiness response. Checks holds "ok" or the error of every
+// check, Details the state published by the components.
+type Report struct {
+	Status  string            `json:"status"`
+	Checks  map[string]string `json:"checks,omitempty"`
+	Details map[string]any    `json:"details,omitempty"`
+}
+
+type Checker struct {
+	timeout time.Duration
+	checks  []Check
+	details map[string]func() any
+}
+
+// New creates a checker running every check with timeout.
+func New(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{
+		timeout: timeout,
+		checks:  checks,
+		details: make(map[string]func() any),
+	}
+}
+
+func (c *Checker) Add(check Check) {
+	c.checks = append(c.checks, check)
+}
+
+// Detail adds the value returned by f to every readiness report under name.
+func (c *Checker) Detail(name string, f func() any) {
+	c.details[name] = f
+}
+
+// Ready runs the checks concurrently, each bounded by the timeout.
+func (c *Checker) Ready(ctx context.Context) *Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report := Report{
+		Status: statusOK,
+		Checks: make(map[string]string, len(c.checks)),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Go(func() {
+			result := statusOK
+			if err := check.Check(ctx); err != nil {
+				result = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result != statusOK {
+				report.Status = statusFail
+			}
+		})
+	}
+	wg.Wait()
+
+	if len(c.details) > 0 {
+		report.Details = make(map[string]any, len(c.details))
+		for name, f := range c.details {
+			report.Details[name] = f()
+		}
+	}
+
+	return &report
+}
+
+// Live answers 200 as long as the process serves requests.
+func Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Report{Status: statusOK})
+}
+
+// Readiness answers 200 when every check passed and 503 otherwise.
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Ready(r.Context())
+	status := http.StatusOK
+	if report.Status != statusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, *report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,77 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ok(context.Context) error { return nil }
+
+func slow(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name           string
+		checks         []Check
+		expectedCode   int
+		expectedChecks map[string]string
+	}{
+		{
+			name:           "all ok",
+			checks:         []Check{{Name: "postgres", Check: ok}, {Name: "queue", Check: ok}},
+			expectedCode:   http.StatusOK,
+			expectedChecks: map[string]string{"postgres": "ok", "queue": "ok"},
+		},
+		{
+			name: "failing dependency",
+			checks: []Check{
+				{Name: "postgres", Check: ok},
+				{Name: "storage", Check: func(context.Context) error { return errors.New("bucket missing") }},
+			},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"postgres": "ok", "storage": "bucket missing"},
+		},
+		{
+			name:           "timeout",
+			checks:         []Check{{Name: "queue", Check: slow}},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"queue": context.DeadlineExceeded.Error()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := New(20*time.Millisecond, tt.checks...)
+			checker.Detail("worker", func() any { return map[string]bool{"consuming": true} })
+			rec := httptest.NewRecorder()
+
+			checker.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			var report Report
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+			assert.Equal(t, tt.expectedChecks, report.Checks)
+			assert.Equal(t, map[string]any{"consuming": true}, report.Details["worker"])
+		})
+	}
+}
+
+func TestLive(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	Live(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
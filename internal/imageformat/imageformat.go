@@ -0,0 +1,97 @@
+package imageformat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	// Decoders for the formats Detect recognizes, DecodeConfig needs them.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	JPEG = "jpeg"
+	PNG  = "png"
+	GIF  = "gif"
+	BMP  = "bmp"
+	TIFF = "tiff"
+	WEBP = "webp"
+
+	// SniffLen is the number of leading bytes Detect needs to recognize every format.
+	SniffLen = 12
+)
+
+// ErrTooManyPixels is returned by CheckPixels for images over the limit.
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+var (
+	signatures = []struct {
+		format string
+		offset int
+		magic  []byte
+	}{
+		{JPEG, 0, []byte("\xff\xd8\xff")},
+		{PNG, 0, []byte("\x89PNG\r\n\x1a\n")},
+		{GIF, 0, []byte("GIF87a")},
+		{GIF, 0, []byte("GIF89a")},
+		{BMP, 0, []byte("BM")},
+		{TIFF, 0, []byte("II*\x00")},
+		{TIFF, 0, []byte("MM\x00*")},
+		{WEBP, 8, []byte("WEBP")},
+	}
+	contentTypes = map[string]string{
+		JPEG: "image/jpeg",
+		PNG:  "image/png",
+		GIF:  "image/gif",
+		BMP:  "image/bmp",
+		TIFF: "image/tiff",
+		WEBP: "image/webp",
+	}
+)
+
+// Detect recognizes the image format by the magic bytes at the start of header.
+func Detect(header []byte) (string, bool) {
+	for _, sig := range signatures {
+		if len(header) < sig.offset+len(sig.magic) {
+			continue
+		}
+		if !bytes.Equal(header[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
+			continue
+		}
+		if sig.format == WEBP && !bytes.HasPrefix(header, []byte("RIFF")) {
+			continue
+		}
+
+		return sig.format, true
+	}
+
+	return "", false
+}
+
+// ContentType returns the MIME type of a format returned by Detect.
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// CheckPixels reads the dimensions from the header of the image in r and
+// rejects an image over maxPixels before any decoder allocates its pixels.
+// A maxPixels of 0 is unlimited.
+func CheckPixels(r io.Reader, maxPixels int64) error {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return fmt.Errorf("failed to decode image header - %w", err)
+	}
+	if maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return fmt.Errorf("%w: %dx%d, at most %d", ErrTooManyPixels, cfg.Width, cfg.Height, maxPixels)
+	}
+
+	return nil
+}
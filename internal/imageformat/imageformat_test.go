@@ -0,0 +1,73 @@
+package imageformat
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         []byte
+		expectedFormat string
+		expectedOK     bool
+	}{
+		{name: "jpeg", header: []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), expectedFormat: JPEG, expectedOK: true},
+		{name: "png", header: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d"), expectedFormat: PNG, expectedOK: true},
+		{name: "gif87a", header: []byte("GIF87a\x01\x00"), expectedFormat: GIF, expectedOK: true},
+		{name: "gif89a", header: []byte("GIF89a\x01\x00"), expectedFormat: GIF, expectedOK: true},
+		{name: "bmp", header: []byte("BM\x36\x00\x00\x00"), expectedFormat: BMP, expectedOK: true},
+		{name: "tiff little endian", header: []byte("II*\x00\x08\x00\x00\x00"), expectedFormat: TIFF, expectedOK: true},
+		{name: "tiff big endian", header: []byte("MM\x00*\x00\x00\x00\x08"), expectedFormat: TIFF, expectedOK: true},
+		{name: "webp", header: []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), expectedFormat: WEBP, expectedOK: true},
+		{name: "riff but not webp", header: []byte("RIFF\x24\x00\x00\x00WAVEfmt "), expectedOK: false},
+		{name: "text", header: []byte("hello, world"), expectedOK: false},
+		{name: "empty", header: []byte{}, expectedOK: false},
+		{name: "too short", header: []byte("\xff\xd8"), expectedOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, ok := Detect(tt.header)
+
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.Equal(t, tt.expectedFormat, format)
+		})
+	}
+}
+
+func TestCheckPixels(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 30))))
+
+	tests := []struct {
+		name        string
+		data        []byte
+		maxPixels   int64
+		expectError error
+	}{
+		{name: "within limit", data: buf.Bytes(), maxPixels: 1200},
+		{name: "unlimited", data: buf.Bytes(), maxPixels: 0},
+		{name: "over limit", data: buf.Bytes(), maxPixels: 1199, expectError: ErrTooManyPixels},
+		{name: "broken header", data: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d"), maxPixels: 1200, expectError: errors.New("failed to decode image header")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckPixels(bytes.NewReader(tt.data), tt.maxPixels)
+
+			if tt.expectError != nil {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
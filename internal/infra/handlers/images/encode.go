@@ -0,0 +1,102 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/avraam311/image-processor/internal/imageformat"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/disintegration/imaging"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+const (
+	defaultJPEGQuality = 90
+	defaultGIFColors   = 256
+)
+
+var (
+	pngCompression = map[string]png.CompressionLevel{
+		"":        png.DefaultCompression,
+		"default": png.DefaultCompression,
+		"none":    png.NoCompression,
+		"fast":    png.BestSpeed,
+		"best":    png.BestCompression,
+	}
+)
+
+// encode writes img in the requested format. Without an explicit format the
+// source format is kept; WebP, which cannot be encoded, becomes PNG when the
+// picture has transparency and JPEG otherwise. Transparent pictures encoded
+// as JPEG are flattened onto the background color of the encode step.
+func encode(img *image.NRGBA, op models.Operation, sourceFormat string) ([]byte, string, error) {
+	format := outputFormat(img, op.Format, sourceFormat)
+
+	var err error
+	buf := new(bytes.Buffer)
+	switch format {
+	case imageformat.JPEG:
+		quality := op.Quality
+		if quality == 0 {
+			quality = defaultJPEGQuality
+		}
+		if !img.Opaque() {
+			background, err := parseColor(op.Background)
+			if err != nil {
+				return nil, "", err
+			}
+			img = imaging.Overlay(imaging.New(img.Bounds().Dx(), img.Bounds().Dy(), background), img, image.Point{}, 1)
+		}
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: quality})
+
+	case imageformat.PNG:
+		level, ok := pngCompression[op.Compression]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown png compression %q", op.Compression)
+		}
+		encoder := png.Encoder{CompressionLevel: level}
+		err = encoder.Encode(buf, img)
+
+	case imageformat.GIF:
+		colors := op.Colors
+		if colors == 0 {
+			colors = defaultGIFColors
+		}
+		err = gif.Encode(buf, img, &gif.Options{NumColors: colors, Quantizer: medianCut{}})
+
+	case imageformat.BMP:
+		err = bmp.Encode(buf, img)
+
+	case imageformat.TIFF:
+		err = tiff.Encode(buf, img, &tiff.Options{Compression: tiff.Deflate})
+
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), imageformat.ContentType(format), nil
+}
+
+func outputFormat(img *image.NRGBA, format, sourceFormat string) string {
+	if format != "" {
+		return format
+	}
+	if sourceFormat != "" && sourceFormat != imageformat.WEBP {
+		return sourceFormat
+	}
+	if !img.Opaque() {
+		return imageformat.PNG
+	}
+
+	return imageformat.JPEG
+}
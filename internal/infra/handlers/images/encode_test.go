@@ -0,0 +1,228 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeGIFPalette(t *testing.T) {
+	stripes := []color.NRGBA{
+		{R: 10, G: 200, B: 30, A: 255},
+		{R: 250, G: 128, B: 5, A: 255},
+		{R: 123, G: 45, B: 67, A: 255},
+	}
+	striped := image.NewNRGBA(image.Rect(0, 0, 30, 10))
+	for x := 0; x < 30; x++ {
+		for y := 0; y < 10; y++ {
+			striped.SetNRGBA(x, y, stripes[x/10])
+		}
+	}
+	gradient := image.NewNRGBA(image.Rect(0, 0, 256, 4))
+	for x := 0; x < 256; x++ {
+		for y := 0; y < 4; y++ {
+			gradient.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(255 - x), B: 40, A: 255})
+		}
+	}
+	transparent := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			transparent.SetNRGBA(x, y, stripes[0])
+		}
+	}
+
+	tests := []struct {
+		name           string
+		img            *image.NRGBA
+		colors         int
+		expectedColors []color.Color
+		maxError       int
+	}{
+		{
+			name:           "exact colors",
+			img:            striped,
+			colors:         4,
+			expectedColors: []color.Color{toRGBA(stripes[0]), toRGBA(stripes[1]), toRGBA(stripes[2])},
+		},
+		{
+			name:     "more colors than slots",
+			img:      gradient,
+			colors:   16,
+			maxError: 10,
+		},
+		{
+			name:           "transparency",
+			img:            transparent,
+			colors:         2,
+			expectedColors: []color.Color{color.RGBA{}, toRGBA(stripes[0])},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, contentType, err := encode(tt.img, models.Operation{Op: "encode", EncodeParams: models.EncodeParams{Format: "gif", Colors: tt.colors}}, "")
+			require.NoError(t, err)
+			assert.Equal(t, "image/gif", contentType)
+
+			decoded, err := gif.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			paletted, ok := decoded.(*image.Paletted)
+			require.True(t, ok)
+			assert.LessOrEqual(t, len(paletted.Palette), tt.colors)
+			if tt.expectedColors != nil {
+				// The encoder pads the palette to a power of two.
+				assert.Subset(t, []color.Color(paletted.Palette), tt.expectedColors)
+			}
+
+			bounds := tt.img.Bounds()
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					want := tt.img.NRGBAAt(x, y)
+					if want.A == 0 {
+						assert.Zero(t, color.NRGBAModel.Convert(paletted.At(x, y)).(color.NRGBA).A)
+						continue
+					}
+					nearest := color.NRGBAModel.Convert(paletted.Palette.Convert(want)).(color.NRGBA)
+					require.LessOrEqual(t, channelError(want, nearest), tt.maxError, "pixel %d,%d", x, y)
+				}
+			}
+		})
+	}
+}
+
+func toRGBA(c color.NRGBA) color.RGBA {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: c.A}
+}
+
+// channelError is the largest difference of a color channel.
+func channelError(a, b color.NRGBA) int {
+	diff := func(x, y uint8) int {
+		if x > y {
+			return int(x - y)
+		}
+		return int(y - x)
+	}
+	return max(diff(a.R, b.R), diff(a.G, b.G), diff(a.B, b.B))
+}
+
+func TestEncodeFormat(t *testing.T) {
+	opaque := imaging.New(8, 8, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+	transparent := imaging.New(8, 8, color.NRGBA{R: 200, G: 100, B: 50, A: 100})
+
+	tests := []struct {
+		name                string
+		img                 *image.NRGBA
+		format              string
+		sourceFormat        string
+		expectedFormat      string
+		expectedContentType string
+	}{
+		{name: "explicit jpeg", img: opaque, format: "jpeg", sourceFormat: "png", expectedFormat: "jpeg", expectedContentType: "image/jpeg"},
+		{name: "explicit png", img: opaque, format: "png", sourceFormat: "jpeg", expectedFormat: "png", expectedContentType: "image/png"},
+		{name: "explicit gif", img: opaque, format: "gif", expectedFormat: "gif", expectedContentType: "image/gif"},
+		{name: "explicit bmp", img: opaque, format: "bmp", expectedFormat: "bmp", expectedContentType: "image/bmp"},
+		{name: "explicit tiff", img: opaque, format: "tiff", expectedFormat: "tiff", expectedContentType: "image/tiff"},
+		{name: "keeps jpeg source", img: opaque, sourceFormat: "jpeg", expectedFormat: "jpeg", expectedContentType: "image/jpeg"},
+		{name: "keeps transparent png source", img: transparent, sourceFormat: "png", expectedFormat: "png", expectedContentType: "image/png"},
+		{name: "keeps gif source", img: opaque, sourceFormat: "gif", expectedFormat: "gif", expectedContentType: "image/gif"},
+		{name: "transparent webp", img: transparent, sourceFormat: "webp", expectedFormat: "png", expectedContentType: "image/png"},
+		{name: "opaque webp", img: opaque, sourceFormat: "webp", expectedFormat: "jpeg", expectedContentType: "image/jpeg"},
+		{name: "unknown opaque source", img: opaque, expectedFormat: "jpeg", expectedContentType: "image/jpeg"},
+		{name: "unknown transparent source", img: transparent, expectedFormat: "png", expectedContentType: "image/png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := models.Operation{Op: "encode", EncodeParams: models.EncodeParams{Format: tt.format}}
+			data, contentType, err := encode(tt.img, op, tt.sourceFormat)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedContentType, contentType)
+
+			_, format, err := image.DecodeConfig(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedFormat, format)
+		})
+	}
+}
+
+func TestEncodeOptions(t *testing.T) {
+	noisy := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			noisy.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 37), G: uint8(y * 53), B: uint8(x * y), A: 255})
+		}
+	}
+	flat := imaging.New(64, 64, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+	size := func(img *image.NRGBA, params models.EncodeParams) int {
+		t.Helper()
+		data, _, err := encode(img, models.Operation{Op: "encode", EncodeParams: params}, "")
+		require.NoError(t, err)
+		return len(data)
+	}
+
+	t.Run("jpeg quality", func(t *testing.T) {
+		low := size(noisy, models.EncodeParams{Format: "jpeg", Quality: 10})
+		high := size(noisy, models.EncodeParams{Format: "jpeg", Quality: 95})
+		assert.Less(t, low, high)
+		assert.Equal(t, size(noisy, models.EncodeParams{Format: "jpeg", Quality: defaultJPEGQuality}), size(noisy, models.EncodeParams{Format: "jpeg"}))
+	})
+
+	t.Run("png compression", func(t *testing.T) {
+		none := size(flat, models.EncodeParams{Format: "png", Compression: "none"})
+		fast := size(flat, models.EncodeParams{Format: "png", Compression: "fast"})
+		best := size(flat, models.EncodeParams{Format: "png", Compression: "best"})
+		assert.Less(t, fast, none)
+		assert.LessOrEqual(t, best, fast)
+		assert.Equal(t, size(flat, models.EncodeParams{Format: "png", Compression: "default"}), size(flat, models.EncodeParams{Format: "png"}))
+	})
+
+	t.Run("unknown png compression", func(t *testing.T) {
+		_, _, err := encode(flat, models.Operation{Op: "encode", EncodeParams: models.EncodeParams{Format: "png", Compression: "max"}}, "")
+		assert.EqualError(t, err, `unknown png compression "max"`)
+	})
+}
+
+func TestEncodeJPEGBackground(t *testing.T) {
+	transparent := imaging.New(16, 16, color.NRGBA{})
+
+	tests := []struct {
+		name        string
+		background  string
+		expected    color.NRGBA
+		expectError string
+	}{
+		{name: "default white", expected: color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+		{name: "red", background: "#ff0000", expected: color.NRGBA{R: 255, A: 255}},
+		{name: "short hex", background: "#00f", expected: color.NRGBA{B: 255, A: 255}},
+		{name: "invalid color", background: "#ff00", expectError: `invalid color "#ff00"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := models.Operation{Op: "encode", EncodeParams: models.EncodeParams{Format: "jpeg"}}
+			op.Background = tt.background
+			data, contentType, err := encode(transparent, op, "png")
+
+			if tt.expectError != "" {
+				assert.EqualError(t, err, tt.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "image/jpeg", contentType)
+			decoded, err := jpeg.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			got := color.NRGBAModel.Convert(decoded.At(8, 8)).(color.NRGBA)
+			// JPEG is lossy, the flattened color only comes back close.
+			assert.LessOrEqual(t, channelError(tt.expected, got), 8, "got %v", got)
+		})
+	}
+}
@@ -2,50 +2,221 @@ package images
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
-	"image/jpeg"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/imageformat"
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
 
 	"github.com/disintegration/imaging"
+
+	// imaging registers the gif, bmp and tiff decoders, webp is added here.
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	thumbnailSize = 100
+	opDecode      = "decode"
+	opEncode      = "encode"
+)
+
+var (
+	ErrDecodeImage = errors.New("failed to decode image")
+	ErrEncodeImage = errors.New("failed to encode image")
 )
 
-type HandlerImage struct{}
+var (
+	filters = map[string]imaging.ResampleFilter{
+		"":           imaging.Lanczos,
+		"lanczos":    imaging.Lanczos,
+		"catmullrom": imaging.CatmullRom,
+		"linear":     imaging.Linear,
+		"nearest":    imaging.NearestNeighbor,
+	}
+	defaultBackground = color.NRGBA{255, 255, 255, 255}
+)
+
+// HandlerImage runs processing pipelines. Images over maxPixels, 0 is
+// unlimited, are rejected from their header before they are decoded.
+type HandlerImage struct {
+	maxPixels int64
+}
 
-func New() *HandlerImage {
-	return &HandlerImage{}
+func New(maxPixels int64) *HandlerImage {
+	return &HandlerImage{maxPixels: maxPixels}
 }
 
-func (h *HandlerImage) ProcessImage(im []byte, processing string) ([]byte, error) {
-	srcImg, err := jpeg.Decode(bytes.NewReader(im))
+// ProcessImage decodes im once, runs every pipeline step on the decoded
+// picture and encodes the result.
+func (h *HandlerImage) ProcessImage(im []byte, pipeline []models.Operation) (*models.ProcessedImage, error) {
+	autoOrient := len(pipeline) > 0 && pipeline[0].Op == "auto-orient"
+	start := time.Now()
+	if h.maxPixels > 0 {
+		if err := imageformat.CheckPixels(bytes.NewReader(im), h.maxPixels); err != nil {
+			observe(opDecode, start, err)
+			return nil, fmt.Errorf("%w: %w", ErrDecodeImage, err)
+		}
+	}
+	srcImg, err := imaging.Decode(bytes.NewReader(im), imaging.AutoOrientation(autoOrient))
+	observe(opDecode, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecodeImage, err)
+	}
+
+	sourceFormat, _ := imageformat.Detect(im)
+	dstImg := imaging.Clone(srcImg)
+	encodeOp := models.Operation{Op: "encode"}
+	for i, op := range pipeline {
+		start := time.Now()
+		dstImg, err = apply(dstImg, op)
+		if op.Op != "auto-orient" && op.Op != "encode" {
+			observe(op.Op, start, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if op.Op == "encode" {
+			encodeOp = op
+		}
+	}
+
+	start = time.Now()
+	data, contentType, err := encode(dstImg, encodeOp, sourceFormat)
+	observe(opEncode, start, err)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrEncodeImage, err)
 	}
 
-	var dstImg *image.NRGBA
+	return &models.ProcessedImage{
+		Data:        data,
+		ContentType: contentType,
+		Original: models.ImageInfo{
+			Width:  srcImg.Bounds().Dx(),
+			Height: srcImg.Bounds().Dy(),
+			Size:   int64(len(im)),
+		},
+		Result: models.ImageInfo{
+			ContentType: contentType,
+			Width:       dstImg.Bounds().Dx(),
+			Height:      dstImg.Bounds().Dy(),
+			Size:        int64(len(data)),
+		},
+	}, nil
+}
+
+// observe records a pipeline step, auto-orient is part of decode.
+func observe(op string, start time.Time, err error) {
+	metrics.Operations.WithLabelValues(op, metrics.Result(err)).Inc()
+	metrics.OperationDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+func apply(img *image.NRGBA, op models.Operation) (*image.NRGBA, error) {
+	switch op.Op {
+	case "auto-orient", "encode":
+		// Handled while decoding and encoding.
+		return img, nil
+
+	case "crop":
+		rect := image.Rect(op.X, op.Y, op.X+op.Width, op.Y+op.Height)
+		if !rect.In(img.Bounds()) {
+			return nil, fmt.Errorf("crop rectangle %v is outside of the image %v", rect, img.Bounds())
+		}
+		return imaging.Crop(img, rect), nil
 
-	switch processing {
 	case "resize":
-		dstImg = imaging.Resize(srcImg, 800, 600, imaging.Lanczos)
+		return resize(img, &op.ProcessingParams)
 
 	case "thumbnail":
-		dstImg = imaging.Thumbnail(srcImg, 100, 100, imaging.Lanczos)
+		width, height := op.Width, op.Height
+		if width == 0 || height == 0 {
+			width, height = thumbnailSize, thumbnailSize
+		}
+		return imaging.Thumbnail(img, width, height, imaging.Lanczos), nil
 
-	case "watermark":
-		dstImg = imaging.Clone(srcImg)
+	case "sharpen":
+		return imaging.Sharpen(img, op.Sigma), nil
+
+	case "blur":
+		return imaging.Blur(img, op.Sigma), nil
 
+	case "grayscale":
+		return imaging.Grayscale(img), nil
+
+	case "watermark":
 		watermark := imaging.New(120, 50, color.NRGBA{0, 0, 0, 0})
 		waterColor := imaging.New(120, 50, image.White)
 		draw.Draw(watermark, watermark.Bounds(), waterColor, image.Point{}, draw.Over)
 
-		dstImg = imaging.Overlay(dstImg, watermark, image.Pt(dstImg.Bounds().Dx()-120, dstImg.Bounds().Dy()-50), 0.5)
+		return imaging.Overlay(img, watermark, image.Pt(img.Bounds().Dx()-120, img.Bounds().Dy()-50), 0.5), nil
 	}
 
-	buf := new(bytes.Buffer)
-	err = jpeg.Encode(buf, dstImg, &jpeg.Options{Quality: 90})
-	if err != nil {
-		return nil, err
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// resize scales srcImg according to the fit mode:
+//   - fill crops the center of the scaled image to exactly width x height;
+//   - fit scales the image to fit inside width x height;
+//   - stretch scales to exactly width x height ignoring the aspect ratio;
+//   - pad fits the image inside width x height and fills the rest with the background.
+//
+// A zero width or height keeps the aspect ratio for fit and stretch.
+func resize(srcImg image.Image, params *models.ProcessingParams) (*image.NRGBA, error) {
+	filter, ok := filters[params.Filter]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q", params.Filter)
+	}
+	width, height := params.Width, params.Height
+	if width == 0 && height == 0 {
+		return nil, fmt.Errorf("width or height required")
+	}
+
+	switch params.Fit {
+	case "fill":
+		return imaging.Fill(srcImg, width, height, imaging.Center, filter), nil
+
+	case "", "fit":
+		if width == 0 || height == 0 {
+			return imaging.Resize(srcImg, width, height, filter), nil
+		}
+		return imaging.Fit(srcImg, width, height, filter), nil
+
+	case "stretch":
+		return imaging.Resize(srcImg, width, height, filter), nil
+
+	case "pad":
+		background, err := parseColor(params.Background)
+		if err != nil {
+			return nil, err
+		}
+		canvas := imaging.New(width, height, background)
+		return imaging.PasteCenter(canvas, imaging.Fit(srcImg, width, height, filter)), nil
+	}
+
+	return nil, fmt.Errorf("unknown fit %q", params.Fit)
+}
+
+// parseColor parses "#rgb" and "#rrggbb" colors, empty means white.
+func parseColor(hex string) (color.NRGBA, error) {
+	if hex == "" {
+		return defaultBackground, nil
+	}
+
+	c := color.NRGBA{A: 255}
+	var err error
+	switch len(hex) {
+	case 7:
+		_, err = fmt.Sscanf(hex, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 4:
+		_, err = fmt.Sscanf(hex, "#%1x%1x%1x", &c.R, &c.G, &c.B)
+		c.R, c.G, c.B = c.R*17, c.G*17, c.B*17
+	default:
+		err = fmt.Errorf("invalid color %q", hex)
 	}
 
-	return buf.Bytes(), nil
+	return c, err
 }
@@ -0,0 +1,173 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/avraam311/image-processor/internal/imageformat"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessImage_MaxPixels(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 30))))
+	pipeline := []models.Operation{{Op: "grayscale"}}
+
+	tests := []struct {
+		name        string
+		maxPixels   int64
+		expectError error
+	}{
+		{name: "unlimited", maxPixels: 0},
+		{name: "within limit", maxPixels: 1200},
+		{name: "over limit", maxPixels: 1199, expectError: imageformat.ErrTooManyPixels},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := New(tt.maxPixels).ProcessImage(buf.Bytes(), pipeline)
+
+			if tt.expectError != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.expectError))
+				assert.True(t, errors.Is(err, ErrDecodeImage))
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, 40, res.Original.Width)
+			}
+		})
+	}
+}
+
+func TestProcessImage_Resize(t *testing.T) {
+	green := color.NRGBA{G: 200, A: 255}
+	src := encodePNG(t, imaging.New(40, 20, green))
+
+	tests := []struct {
+		name           string
+		params         models.ProcessingParams
+		expectedWidth  int
+		expectedHeight int
+		expectError    string
+	}{
+		{name: "fill", params: models.ProcessingParams{Width: 20, Height: 20, Fit: "fill"}, expectedWidth: 20, expectedHeight: 20},
+		{name: "fit", params: models.ProcessingParams{Width: 20, Height: 20, Fit: "fit"}, expectedWidth: 20, expectedHeight: 10},
+		{name: "default fit", params: models.ProcessingParams{Width: 20, Height: 20}, expectedWidth: 20, expectedHeight: 10},
+		{name: "stretch", params: models.ProcessingParams{Width: 20, Height: 20, Fit: "stretch"}, expectedWidth: 20, expectedHeight: 20},
+		{name: "pad", params: models.ProcessingParams{Width: 20, Height: 20, Fit: "pad"}, expectedWidth: 20, expectedHeight: 20},
+		{name: "width only", params: models.ProcessingParams{Width: 20}, expectedWidth: 20, expectedHeight: 10},
+		{name: "height only", params: models.ProcessingParams{Height: 5}, expectedWidth: 10, expectedHeight: 5},
+		{name: "stretch width only", params: models.ProcessingParams{Width: 80, Fit: "stretch"}, expectedWidth: 80, expectedHeight: 40},
+		{name: "nearest filter", params: models.ProcessingParams{Width: 20, Filter: "nearest"}, expectedWidth: 20, expectedHeight: 10},
+		{name: "no size", params: models.ProcessingParams{Fit: "fill"}, expectError: "width or height required"},
+		{name: "unknown filter", params: models.ProcessingParams{Width: 20, Filter: "box"}, expectError: `unknown filter "box"`},
+		{name: "unknown fit", params: models.ProcessingParams{Width: 20, Fit: "cover"}, expectError: `unknown fit "cover"`},
+		{name: "invalid color", params: models.ProcessingParams{Width: 20, Height: 20, Fit: "pad", Background: "#12345"}, expectError: `invalid color "#12345"`},
+		{name: "invalid hex digits", params: models.ProcessingParams{Width: 20, Height: 20, Fit: "pad", Background: "#gg0000"}, expectError: "expected integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := New(0).ProcessImage(src, []models.Operation{{Op: "resize", ProcessingParams: tt.params}})
+
+			if tt.expectError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "image/png", res.ContentType)
+			assert.Equal(t, tt.expectedWidth, res.Result.Width)
+			assert.Equal(t, tt.expectedHeight, res.Result.Height)
+
+			decoded, err := png.Decode(bytes.NewReader(res.Data))
+			require.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, tt.expectedWidth, tt.expectedHeight), decoded.Bounds())
+		})
+	}
+}
+
+func TestProcessImage_PadBackground(t *testing.T) {
+	green := color.NRGBA{G: 200, A: 255}
+	src := encodePNG(t, imaging.New(40, 20, green))
+
+	tests := []struct {
+		name       string
+		background string
+		expected   color.NRGBA
+	}{
+		{name: "default white", expected: color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+		{name: "long hex", background: "#102030", expected: color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 255}},
+		{name: "short hex", background: "#f00", expected: color.NRGBA{R: 255, A: 255}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := models.Operation{Op: "resize", ProcessingParams: models.ProcessingParams{Width: 40, Height: 40, Fit: "pad", Background: tt.background}}
+			res, err := New(0).ProcessImage(src, []models.Operation{op})
+			require.NoError(t, err)
+
+			decoded, err := png.Decode(bytes.NewReader(res.Data))
+			require.NoError(t, err)
+			// The 40x20 picture is centered, leaving 10 rows above and below.
+			assert.Equal(t, tt.expected, color.NRGBAModel.Convert(decoded.At(0, 0)))
+			assert.Equal(t, tt.expected, color.NRGBAModel.Convert(decoded.At(39, 39)))
+			assert.Equal(t, green, color.NRGBAModel.Convert(decoded.At(20, 20)))
+		})
+	}
+}
+
+func TestProcessImage_Filter(t *testing.T) {
+	checker := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	checker.SetNRGBA(0, 0, color.NRGBA{A: 255})
+	checker.SetNRGBA(1, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	checker.SetNRGBA(0, 1, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	checker.SetNRGBA(1, 1, color.NRGBA{A: 255})
+	src := encodePNG(t, checker)
+
+	tests := []struct {
+		filter       string
+		expectBlends bool
+	}{
+		{filter: "nearest", expectBlends: false},
+		{filter: "linear", expectBlends: true},
+		{filter: "", expectBlends: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("filter "+tt.filter, func(t *testing.T) {
+			op := models.Operation{Op: "resize", ProcessingParams: models.ProcessingParams{Width: 8, Height: 8, Fit: "stretch", Filter: tt.filter}}
+			res, err := New(0).ProcessImage(src, []models.Operation{op})
+			require.NoError(t, err)
+
+			decoded, err := png.Decode(bytes.NewReader(res.Data))
+			require.NoError(t, err)
+			blends := false
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 8; x++ {
+					c := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+					if c.R != 0 && c.R != 255 {
+						blends = true
+					}
+				}
+			}
+			assert.Equal(t, tt.expectBlends, blends)
+		})
+	}
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	return buf.Bytes()
+}
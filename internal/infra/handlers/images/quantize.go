@@ -0,0 +1,155 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// medianCut is a draw.Quantizer building the palette from the colors of the
+// picture: the colors are split into boxes at the median of their widest
+// channel until there is a box per palette slot, each box gives its average
+// color. Pictures with fewer colors than slots keep their exact colors.
+// Mostly transparent pixels share one transparent entry.
+type medianCut struct{}
+
+// colorCount is a color of the picture and how many pixels have it.
+type colorCount struct {
+	rgb   [3]uint8
+	count int
+}
+
+type colorBox []colorCount
+
+func (medianCut) Quantize(p color.Palette, m image.Image) color.Palette {
+	slots := cap(p) - len(p)
+	if slots <= 0 {
+		return p
+	}
+
+	counts := map[[3]uint8]int{}
+	transparent := false
+	bounds := m.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				transparent = true
+				continue
+			}
+			counts[[3]uint8{c.R, c.G, c.B}]++
+		}
+	}
+	if transparent {
+		p = append(p, color.RGBA{})
+		slots--
+	}
+	if slots <= 0 || len(counts) == 0 {
+		return p
+	}
+
+	colors := make(colorBox, 0, len(counts))
+	for rgb, count := range counts {
+		colors = append(colors, colorCount{rgb: rgb, count: count})
+	}
+	// Map order is random, sorting keeps the palette of a picture stable.
+	sort.Slice(colors, func(i, j int) bool { return colors[i].less(colors[j]) })
+
+	boxes := []colorBox{colors}
+	for len(boxes) < slots {
+		i, channel := widestBox(boxes)
+		if i < 0 {
+			break
+		}
+		low, high := boxes[i].split(channel)
+		boxes[i] = low
+		boxes = append(boxes, high)
+	}
+	for _, box := range boxes {
+		p = append(p, box.average())
+	}
+
+	return p
+}
+
+func (c colorCount) less(other colorCount) bool {
+	for ch := range c.rgb {
+		if c.rgb[ch] != other.rgb[ch] {
+			return c.rgb[ch] < other.rgb[ch]
+		}
+	}
+
+	return false
+}
+
+// widestBox returns the box with the widest channel range and that channel,
+// or -1 when no box has more than one color left.
+func widestBox(boxes []colorBox) (int, int) {
+	best, bestChannel, bestRange := -1, 0, 0
+	for i, box := range boxes {
+		if len(box) < 2 {
+			continue
+		}
+		channel, width := box.widestChannel()
+		if width > bestRange || best < 0 {
+			best, bestChannel, bestRange = i, channel, width
+		}
+	}
+
+	return best, bestChannel
+}
+
+func (b colorBox) widestChannel() (int, int) {
+	channel, width := 0, -1
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := b[0].rgb[ch], b[0].rgb[ch]
+		for _, c := range b[1:] {
+			lo, hi = min(lo, c.rgb[ch]), max(hi, c.rgb[ch])
+		}
+		if int(hi-lo) > width {
+			channel, width = ch, int(hi-lo)
+		}
+	}
+
+	return channel, width
+}
+
+// split sorts the box along channel and cuts it where half of its pixels are
+// on either side, both halves keep at least one color.
+func (b colorBox) split(channel int) (colorBox, colorBox) {
+	sort.SliceStable(b, func(i, j int) bool { return b[i].rgb[channel] < b[j].rgb[channel] })
+
+	total := 0
+	for _, c := range b {
+		total += c.count
+	}
+	cut, seen := 1, 0
+	for i, c := range b[:len(b)-1] {
+		seen += c.count
+		cut = i + 1
+		if seen*2 >= total {
+			break
+		}
+	}
+
+	return b[:cut], b[cut:]
+}
+
+// average is the color of the box weighted by pixel counts.
+func (b colorBox) average() color.Color {
+	var sum [3]int
+	total := 0
+	for _, c := range b {
+		for ch := range sum {
+			sum[ch] += int(c.rgb[ch]) * c.count
+		}
+		total += c.count
+	}
+
+	return color.RGBA{
+		R: uint8((sum[0] + total/2) / total),
+		G: uint8((sum[1] + total/2) / total),
+		B: uint8((sum[2] + total/2) / total),
+		A: 255,
+	}
+}
@@ -0,0 +1,117 @@
+// Package outbox publishes jobs written to the outbox table together with
+// their image to the job queue. A job is published at least once and only after the original
+// is stored; uploads that never got that far are cleaned up.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/infra/queue"
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/wb-go/wbf/config"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	RelayOutbox(context.Context, int, func([]models.OutboxMessage) error) (int, error)
+	ListStaleUploads(context.Context, time.Duration, int) ([]uuid.UUID, error)
+	DeleteImage(context.Context, uuid.UUID) error
+	DeleteExpiredIdempotencyKeys(context.Context, int) (int, error)
+}
+
+type Relay struct {
+	repo  Repository
+	jobs  queue.JobQueue
+	cfg   *config.Config
+	store storage.ObjectStore
+}
+
+func NewRelay(repo Repository, jobs queue.JobQueue, cfg *config.Config, store storage.ObjectStore) *Relay {
+	return &Relay{
+		repo:  repo,
+		jobs:  jobs,
+		cfg:   cfg,
+		store: store,
+	}
+}
+
+// Run publishes ready jobs every outbox.interval until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.GetDuration("outbox.interval"))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.relay(ctx)
+			r.cleanup(ctx)
+		}
+	}
+}
+
+// relay publishes batches until the outbox has no ready jobs left.
+func (r *Relay) relay(ctx context.Context) {
+	batchSize := r.cfg.GetInt("outbox.batch_size")
+	for {
+		n, err := r.repo.RelayOutbox(ctx, batchSize, func(messages []models.OutboxMessage) error {
+			return r.publish(ctx, messages)
+		})
+		if err != nil {
+			zlog.Logger.Warn().Err(err).Msg("relay.go - failed to relay outbox")
+			return
+		}
+		if n > 0 {
+			zlog.Logger.Debug().Int("messages", n).Msg("relay.go - outbox relayed")
+		}
+		if n < batchSize {
+			return
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, messages []models.OutboxMessage) error {
+	jobs := make([]queue.Message, 0, len(messages))
+	for _, msg := range messages {
+		jobs = append(jobs, queue.Message{Key: []byte(msg.ImageID.String()), Value: msg.Payload})
+	}
+
+	return r.jobs.Publish(ctx, jobs...)
+}
+
+// cleanup removes uploads whose original was not stored within
+// outbox.upload_timeout, typically because the API crashed mid-upload, and
+// expired idempotency keys.
+func (r *Relay) cleanup(ctx context.Context) {
+	n, err := r.repo.DeleteExpiredIdempotencyKeys(ctx, r.cfg.GetInt("outbox.batch_size"))
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Msg("relay.go - failed to delete expired idempotency keys")
+	} else if n > 0 {
+		zlog.Logger.Debug().Int("keys", n).Msg("relay.go - expired idempotency keys deleted")
+	}
+
+	ids, err := r.repo.ListStaleUploads(ctx, r.cfg.GetDuration("outbox.upload_timeout"), r.cfg.GetInt("outbox.batch_size"))
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Msg("relay.go - failed to list stale uploads")
+		return
+	}
+
+	for _, id := range ids {
+		err := r.store.Delete(ctx, storage.OriginalKey(id))
+		if err != nil {
+			zlog.Logger.Warn().Err(err).Stringer("image", id).Msg("relay.go - failed to remove stale upload")
+			continue
+		}
+		if err := r.repo.DeleteImage(ctx, id); err != nil {
+			zlog.Logger.Warn().Err(err).Stringer("image", id).Msg("relay.go - failed to delete stale upload")
+			continue
+		}
+		zlog.Logger.Info().Stringer("image", id).Msg("relay.go - stale upload removed")
+	}
+}
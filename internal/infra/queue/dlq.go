@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+// Headers set on dead-lettered messages. All of them share headerPrefix and
+// are removed again when a message is replayed.
+const (
+	headerPrefix            = "x-dlq-"
+	HeaderReason            = headerPrefix + "reason"
+	HeaderError             = headerPrefix + "error"
+	HeaderAttempts          = headerPrefix + "attempts"
+	HeaderOriginalTopic     = headerPrefix + "original-topic"
+	HeaderOriginalPartition = headerPrefix + "original-partition"
+	HeaderOriginalOffset    = headerPrefix + "original-offset"
+	HeaderOriginalTimestamp = headerPrefix + "original-timestamp"
+	HeaderFailedAt          = headerPrefix + "failed-at"
+	HeaderReplayedAt        = "x-replayed-at"
+)
+
+// DeadLetter describes why a message is moved to the dead-letter queue.
+type DeadLetter struct {
+	Reason   string
+	Err      error
+	Attempts int
+}
+
+// DeadLetterMessage copies msg for the dead-letter queue, with headers
+// describing the failure and where the message came from. Poison messages,
+// the ones that fail the same way however often they are delivered, are
+// kept there so they can be inspected and replayed once the cause is fixed.
+func DeadLetterMessage(msg Message, dl DeadLetter) Message {
+	headers := withoutDLQHeaders(msg.Headers)
+	headers[HeaderReason] = dl.Reason
+	headers[HeaderAttempts] = strconv.Itoa(dl.Attempts)
+	headers[HeaderOriginalTopic] = msg.Queue
+	headers[HeaderOriginalPartition] = strconv.Itoa(msg.Partition)
+	headers[HeaderOriginalOffset] = strconv.FormatInt(msg.Offset, 10)
+	headers[HeaderOriginalTimestamp] = msg.Time.UTC().Format(time.RFC3339Nano)
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+	if dl.Err != nil {
+		headers[HeaderError] = dl.Err.Error()
+	}
+
+	return Message{Key: msg.Key, Value: msg.Value, Headers: headers}
+}
+
+// ReplayOptions limits a replay. Replay stops after Limit messages when Limit
+// is positive, or when no message arrives for Idle. DryRun only logs the
+// messages and nacks them, it stops once a message comes around again.
+type ReplayOptions struct {
+	Limit  int
+	Idle   time.Duration
+	DryRun bool
+}
+
+// Replay moves dead-lettered messages back to the main queue. A message is
+// acknowledged on the dead-letter queue only after it is published, so an
+// interrupted replay may send a message twice but never loses one.
+func Replay(ctx context.Context, dlq, target JobQueue, opts ReplayOptions) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	messages, err := dlq.Consume(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	idle := time.NewTimer(opts.Idle)
+	defer idle.Stop()
+
+	type position struct {
+		partition int
+		offset    int64
+	}
+	seen := map[position]bool{}
+
+	replayed := 0
+	for opts.Limit <= 0 || replayed < opts.Limit {
+		var msg Message
+		select {
+		case <-ctx.Done():
+			return replayed, ctx.Err()
+		case <-idle.C:
+			return replayed, nil
+		case m, ok := <-messages:
+			if !ok {
+				return replayed, nil
+			}
+			msg = m
+		}
+		idle.Reset(opts.Idle)
+
+		logger := zlog.Logger.Info().
+			Str("key", string(msg.Key)).
+			Str("reason", msg.Headers[HeaderReason]).
+			Str("error", msg.Headers[HeaderError]).
+			Str("attempts", msg.Headers[HeaderAttempts]).
+			Str("failed_at", msg.Headers[HeaderFailedAt])
+		if opts.DryRun {
+			pos := position{partition: msg.Partition, offset: msg.Offset}
+			if err := dlq.Nack(ctx, msg); err != nil {
+				return replayed, fmt.Errorf("dlq.go - failed to nack dead letter - %w", err)
+			}
+			if seen[pos] {
+				return replayed, nil
+			}
+			seen[pos] = true
+			logger.Msg("dead letter")
+			replayed++
+			continue
+		}
+
+		headers := withoutDLQHeaders(msg.Headers)
+		headers[HeaderReplayedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+		if err := target.Publish(ctx, Message{Key: msg.Key, Value: msg.Value, Headers: headers}); err != nil {
+			return replayed, fmt.Errorf("dlq.go - failed to replay dead letter - %w", err)
+		}
+		if err := dlq.Ack(ctx, msg); err != nil {
+			return replayed, fmt.Errorf("dlq.go - failed to ack dead letter - %w", err)
+		}
+		logger.Msg("dead letter replayed")
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+func withoutDLQHeaders(headers map[string]string) map[string]string {
+	kept := maps.Clone(headers)
+	if kept == nil {
+		kept = map[string]string{}
+	}
+	maps.DeleteFunc(kept, func(key, _ string) bool {
+		return strings.HasPrefix(key, headerPrefix) || key == HeaderReplayedAt
+	})
+
+	return kept
+}
@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterMessage(t *testing.T) {
+	msg := Message{
+		Key:       []byte("42"),
+		Value:     []byte("{}"),
+		Headers:   map[string]string{"trace-id": "abc", HeaderReason: "stale"},
+		Queue:     "images",
+		Partition: 2,
+		Offset:    7,
+		Time:      time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	dead := DeadLetterMessage(msg, DeadLetter{Reason: "decode_failed", Err: errors.New("unexpected EOF"), Attempts: 3})
+
+	assert.Equal(t, msg.Key, dead.Key)
+	assert.Equal(t, msg.Value, dead.Value)
+	assert.Equal(t, "abc", dead.Headers["trace-id"])
+	assert.Equal(t, "decode_failed", dead.Headers[HeaderReason])
+	assert.Equal(t, "unexpected EOF", dead.Headers[HeaderError])
+	assert.Equal(t, "3", dead.Headers[HeaderAttempts])
+	assert.Equal(t, "images", dead.Headers[HeaderOriginalTopic])
+	assert.Equal(t, "2", dead.Headers[HeaderOriginalPartition])
+	assert.Equal(t, "7", dead.Headers[HeaderOriginalOffset])
+	assert.Equal(t, "2025-12-01T12:00:00Z", dead.Headers[HeaderOriginalTimestamp])
+	assert.NotEmpty(t, dead.Headers[HeaderFailedAt])
+	assert.Equal(t, "stale", msg.Headers[HeaderReason], "original headers must not change")
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          ReplayOptions
+		expectedCount int
+		expectedLeft  int
+		expectedMoved int
+	}{
+		{
+			name:          "all",
+			opts:          ReplayOptions{Idle: 50 * time.Millisecond},
+			expectedCount: 3,
+			expectedMoved: 3,
+		},
+		{
+			name:          "limit",
+			opts:          ReplayOptions{Idle: 50 * time.Millisecond, Limit: 2},
+			expectedCount: 2,
+			expectedLeft:  1,
+			expectedMoved: 2,
+		},
+		{
+			name:          "dry run",
+			opts:          ReplayOptions{Idle: 50 * time.Millisecond, DryRun: true},
+			expectedCount: 3,
+			expectedLeft:  3,
+			expectedMoved: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dlq, jobs := NewMemory("images-dlq", 10), NewMemory("images", 10)
+			for _, key := range []string{"1", "2", "3"} {
+				dead := DeadLetterMessage(Message{Key: []byte(key)}, DeadLetter{Reason: "decode_failed"})
+				require.NoError(t, dlq.Publish(ctx, dead))
+			}
+
+			n, err := Replay(ctx, dlq, jobs, tt.opts)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCount, n)
+			assert.Equal(t, tt.expectedMoved, jobs.Len())
+			// Give the consumer goroutine time to hand back what it held.
+			assert.Eventually(t, func() bool { return dlq.Len() == tt.expectedLeft }, time.Second, 10*time.Millisecond)
+			if tt.expectedMoved > 0 {
+				msg := <-jobs.messages
+				assert.NotContains(t, msg.Headers, HeaderReason)
+				assert.Contains(t, msg.Headers, HeaderReplayedAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	wbKafka "github.com/wb-go/wbf/kafka"
+	"github.com/wb-go/wbf/zlog"
+)
+
+// nackDelay is how long a nacked message waits before it is delivered again.
+const nackDelay = time.Second
+
+// consumer is the part of the Kafka consumer group reader the queue uses.
+type consumer interface {
+	Fetch(ctx context.Context) (kafka.Message, error)
+	Commit(ctx context.Context, msg kafka.Message) error
+	Close() error
+}
+
+// Kafka is a JobQueue on a Kafka topic. An offset is committed only after the
+// message is acknowledged, and never past a message that is still being
+// processed. A consumer group can't seek, so nacked messages stay in flight
+// and are delivered again by the consumer itself after nackDelay; commits of
+// their partition resume once they are acknowledged.
+type Kafka struct {
+	Prod *wbKafka.Producer
+
+	brokers   []string
+	topic     string
+	groupID   string
+	nackDelay time.Duration
+	mu        sync.Mutex
+	cons      consumer
+	nacked    []Message
+	offsets   *offsets
+}
+
+// NewKafka creates the producer right away. The consumer joins groupID on the
+// first Consume, so publishing processes never join the group.
+func NewKafka(brokers []string, topic string, groupID string) *Kafka {
+	return &Kafka{
+		Prod:      wbKafka.NewProducer(brokers, topic),
+		brokers:   brokers,
+		topic:     topic,
+		groupID:   groupID,
+		nackDelay: nackDelay,
+		offsets:   newOffsets(),
+	}
+}
+
+func (k *Kafka) Publish(ctx context.Context, msgs ...Message) error {
+	kafkaMessages := make([]kafka.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		kafkaMessage := kafka.Message{Key: msg.Key, Value: msg.Value}
+		for key, value := range msg.Headers {
+			kafkaMessage.Headers = append(kafkaMessage.Headers, kafka.Header{Key: key, Value: []byte(value)})
+		}
+		kafkaMessages = append(kafkaMessages, kafkaMessage)
+	}
+
+	if err := k.Prod.Writer.WriteMessages(ctx, kafkaMessages...); err != nil {
+		return fmt.Errorf("kafka.go - failed to publish messages - %w", err)
+	}
+
+	return nil
+}
+
+// Consume fetches messages without committing them until ctx is done.
+// Nacked messages are delivered again on the same channel.
+func (k *Kafka) Consume(ctx context.Context) (<-chan Message, error) {
+	k.mu.Lock()
+	if k.cons == nil {
+		k.cons = wbKafka.NewConsumer(k.brokers, k.topic, k.groupID)
+	}
+	cons := k.cons
+	k.mu.Unlock()
+
+	out := make(chan Message)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for {
+			kafkaMessage, err := cons.Fetch(ctx)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					zlog.Logger.Warn().Err(err).Msg("kafka.go - failed to fetch message from kafka")
+					continue
+				}
+			}
+			k.offsets.partition(kafkaMessage.Topic, kafkaMessage.Partition).track(kafkaMessage.Offset)
+
+			msg := fromKafkaMessage(kafkaMessage)
+			if len(msg.Value) == 0 && len(msg.Key) == 0 {
+				if err := k.Ack(ctx, msg); err != nil {
+					zlog.Logger.Warn().Err(err).Msg("kafka.go - failed to commit empty message")
+				}
+				continue
+			}
+
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		k.redeliver(ctx, out)
+	}()
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+// redeliver sends the nacked messages to out every nackDelay until ctx is
+// done. Messages left over stay uncommitted and come back after a restart.
+func (k *Kafka) redeliver(ctx context.Context, out chan<- Message) {
+	ticker := time.NewTicker(k.nackDelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		k.mu.Lock()
+		msgs := k.nacked
+		k.nacked = nil
+		k.mu.Unlock()
+
+		for i, msg := range msgs {
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				k.mu.Lock()
+				k.nacked = append(msgs[i:], k.nacked...)
+				k.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// Ack marks msg as handled and commits its partition up to the oldest
+// message that is still in flight.
+func (k *Kafka) Ack(ctx context.Context, msg Message) error {
+	p := k.offsets.partition(msg.Queue, msg.Partition)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	offset, ok := p.complete(msg.Offset)
+	if !ok {
+		return nil
+	}
+
+	err := k.cons.Commit(ctx, kafka.Message{Topic: msg.Queue, Partition: msg.Partition, Offset: offset})
+	if err != nil {
+		return fmt.Errorf("kafka.go - failed to commit message - %w", err)
+	}
+
+	return nil
+}
+
+// Nack leaves msg uncommitted and queues it to be delivered again. Commits
+// of its partition wait until it is acknowledged.
+func (k *Kafka) Nack(ctx context.Context, msg Message) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.nacked = append(k.nacked, msg)
+
+	return nil
+}
+
+// Ping succeeds when any of the brokers accepts a connection.
+func (k *Kafka) Ping(ctx context.Context) error {
+	var err error
+	for _, broker := range k.brokers {
+		var conn *kafka.Conn
+		conn, err = kafka.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			return conn.Close()
+		}
+	}
+	if err == nil {
+		err = errors.New("no brokers configured")
+	}
+
+	return fmt.Errorf("kafka.go - failed to reach brokers - %w", err)
+}
+
+func (k *Kafka) Close() error {
+	err := k.Prod.Close()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.cons != nil {
+		if consErr := k.cons.Close(); consErr != nil && err == nil {
+			err = consErr
+		}
+	}
+
+	return err
+}
+
+func fromKafkaMessage(kafkaMessage kafka.Message) Message {
+	msg := Message{
+		Key:       kafkaMessage.Key,
+		Value:     kafkaMessage.Value,
+		Headers:   make(map[string]string, len(kafkaMessage.Headers)),
+		Queue:     kafkaMessage.Topic,
+		Partition: kafkaMessage.Partition,
+		Offset:    kafkaMessage.Offset,
+		Time:      kafkaMessage.Time,
+	}
+	for _, h := range kafkaMessage.Headers {
+		msg.Headers[h.Key] = string(h.Value)
+	}
+
+	return msg
+}
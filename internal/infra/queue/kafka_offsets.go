@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"slices"
+	"sync"
+)
+
+// offsets tracks fetched messages per partition. Workers finish jobs out of
+// order, but a Kafka offset commit acknowledges every message before it, so
+// a partition is committed only up to the oldest message still in flight.
+type offsets struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+}
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+type partitionOffsets struct {
+	// mu is held while the offset is committed, so commits of one partition
+	// reach the broker in order.
+	mu      sync.Mutex
+	pending []int64
+	done    map[int64]bool
+}
+
+func newOffsets() *offsets {
+	return &offsets{
+		partitions: make(map[partitionKey]*partitionOffsets),
+	}
+}
+
+func (o *offsets) partition(topic string, partition int) *partitionOffsets {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key := partitionKey{topic: topic, partition: partition}
+	p, ok := o.partitions[key]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]bool)}
+		o.partitions[key] = p
+	}
+
+	return p
+}
+
+// track registers a fetched message, messages must be tracked in fetch order.
+// An offset at or before the last tracked one means the partition was
+// rewound by a rebalance, the in-flight state is dropped then since those
+// messages are delivered again.
+func (p *partitionOffsets) track(offset int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n := len(p.pending); n > 0 && offset <= p.pending[n-1] {
+		p.pending = nil
+		p.done = make(map[int64]bool)
+	}
+	p.pending = append(p.pending, offset)
+}
+
+// complete marks offset as processed and returns the highest offset whose
+// predecessors are all processed. ok is false when nothing new can be
+// committed. Offsets that are not in flight, like ones dropped by a rewind,
+// are ignored. The caller must hold p.mu.
+func (p *partitionOffsets) complete(offset int64) (commit int64, ok bool) {
+	if !slices.Contains(p.pending, offset) {
+		return 0, false
+	}
+	p.done[offset] = true
+	for len(p.pending) > 0 && p.done[p.pending[0]] {
+		commit, ok = p.pending[0], true
+		delete(p.done, p.pending[0])
+		p.pending = p.pending[1:]
+	}
+
+	return commit, ok
+}
@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionOffsets_Complete(t *testing.T) {
+	type step struct {
+		offset     int64
+		wantCommit int64
+		wantOK     bool
+	}
+
+	tests := []struct {
+		name    string
+		tracked []int64
+		steps   []step
+	}{
+		{
+			name:    "in order",
+			tracked: []int64{10, 11, 12},
+			steps: []step{
+				{offset: 10, wantCommit: 10, wantOK: true},
+				{offset: 11, wantCommit: 11, wantOK: true},
+				{offset: 12, wantCommit: 12, wantOK: true},
+			},
+		},
+		{
+			name:    "out of order waits for the oldest",
+			tracked: []int64{10, 11, 12},
+			steps: []step{
+				{offset: 12, wantOK: false},
+				{offset: 11, wantOK: false},
+				{offset: 10, wantCommit: 12, wantOK: true},
+			},
+		},
+		{
+			name:    "gaps in offsets",
+			tracked: []int64{10, 15, 20},
+			steps: []step{
+				{offset: 15, wantOK: false},
+				{offset: 10, wantCommit: 15, wantOK: true},
+				{offset: 20, wantCommit: 20, wantOK: true},
+			},
+		},
+		{
+			name:    "rewind drops in-flight state",
+			tracked: []int64{10, 11, 5, 6},
+			steps: []step{
+				{offset: 11, wantOK: false},
+				{offset: 5, wantCommit: 5, wantOK: true},
+				{offset: 6, wantCommit: 6, wantOK: true},
+			},
+		},
+		{
+			name:    "unknown offset is ignored",
+			tracked: []int64{10},
+			steps: []step{
+				{offset: 9, wantOK: false},
+				{offset: 10, wantCommit: 10, wantOK: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOffsets()
+			p := o.partition("images", 0)
+			for _, offset := range tt.tracked {
+				p.track(offset)
+			}
+
+			for _, s := range tt.steps {
+				commit, ok := p.complete(s.offset)
+				assert.Equal(t, s.wantOK, ok, "offset %d", s.offset)
+				if s.wantOK {
+					assert.Equal(t, s.wantCommit, commit, "offset %d", s.offset)
+				}
+			}
+		})
+	}
+}
+
+func TestOffsets_PartitionsAreIndependent(t *testing.T) {
+	o := newOffsets()
+	o.partition("images", 0).track(1)
+	o.partition("images", 1).track(1)
+
+	commit, ok := o.partition("images", 1).complete(1)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), commit)
+	assert.Equal(t, []int64{1}, o.partition("images", 0).pending)
+}
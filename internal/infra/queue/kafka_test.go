@@ -0,0 +1,92 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConsumer struct {
+	mu        sync.Mutex
+	messages  chan kafka.Message
+	committed []int64
+}
+
+func (c *fakeConsumer) Fetch(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-c.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (c *fakeConsumer) Commit(ctx context.Context, msg kafka.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.committed = append(c.committed, msg.Offset)
+
+	return nil
+}
+
+func (c *fakeConsumer) Close() error {
+	return nil
+}
+
+func (c *fakeConsumer) commits() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]int64(nil), c.committed...)
+}
+
+func TestKafka_NackRedeliversAndResumesCommits(t *testing.T) {
+	cons := &fakeConsumer{messages: make(chan kafka.Message, 3)}
+	for offset := int64(10); offset <= 12; offset++ {
+		cons.messages <- kafka.Message{Topic: "jobs", Offset: offset, Value: []byte("job")}
+	}
+
+	k := NewKafka([]string{"localhost:9092"}, "jobs", "workers")
+	k.cons = cons
+	k.nackDelay = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out, err := k.Consume(ctx)
+	require.NoError(t, err)
+
+	receive := func() Message {
+		select {
+		case msg := <-out:
+			return msg
+		case <-time.After(time.Second):
+			require.FailNow(t, "no message delivered")
+			return Message{}
+		}
+	}
+
+	first := receive()
+	require.Equal(t, int64(10), first.Offset)
+	require.NoError(t, k.Nack(ctx, first))
+
+	for _, offset := range []int64{11, 12} {
+		msg := receive()
+		require.Equal(t, offset, msg.Offset)
+		require.NoError(t, k.Ack(ctx, msg))
+	}
+	assert.Empty(t, cons.commits())
+
+	again := receive()
+	require.Equal(t, int64(10), again.Offset)
+	require.NoError(t, k.Ack(ctx, again))
+	assert.Equal(t, []int64{12}, cons.commits())
+
+	cancel()
+	for range out {
+	}
+}
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+// Memory is an in-process JobQueue on a buffered channel, for tests and the
+// single-binary mode. Messages are lost when the process exits. A nacked
+// message is put back at the end of the queue.
+type Memory struct {
+	name     string
+	messages chan Message
+	// dropWhenFull makes Publish drop messages instead of blocking, for
+	// queues nothing consumes such as the dead-letter queue.
+	dropWhenFull bool
+
+	mu     sync.Mutex
+	offset int64
+}
+
+func NewMemory(name string, size int) *Memory {
+	return &Memory{
+		name:     name,
+		messages: make(chan Message, size),
+	}
+}
+
+// Publish blocks while the buffer is full, or drops and logs the message
+// when the queue drops messages.
+func (m *Memory) Publish(ctx context.Context, msgs ...Message) error {
+	for _, msg := range msgs {
+		m.mu.Lock()
+		m.offset++
+		msg.Queue, msg.Offset, msg.Time = m.name, m.offset, time.Now()
+		m.mu.Unlock()
+
+		if m.dropWhenFull {
+			select {
+			case m.messages <- msg:
+			default:
+				zlog.Logger.Warn().Str("queue", m.name).Bytes("key", msg.Key).Msg("memory.go - queue is full, message dropped")
+			}
+			continue
+		}
+
+		select {
+		case m.messages <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Consume delivers messages until ctx is done. Concurrent consumers share
+// the messages.
+func (m *Memory) Consume(ctx context.Context) (<-chan Message, error) {
+	out := make(chan Message)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-m.messages:
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+					// Keep the message for the next consumer.
+					go func() { m.messages <- msg }()
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (m *Memory) Ack(ctx context.Context, msg Message) error {
+	return nil
+}
+
+func (m *Memory) Nack(ctx context.Context, msg Message) error {
+	go func() { m.messages <- msg }()
+	return nil
+}
+
+// Len returns the number of queued messages.
+func (m *Memory) Len() int {
+	return len(m.messages)
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
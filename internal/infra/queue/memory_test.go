@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_PublishConsume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := NewMemory("images", 10)
+	require.NoError(t, q.Publish(ctx, Message{Key: []byte("1")}, Message{Key: []byte("2")}))
+
+	messages, err := q.Consume(ctx)
+	require.NoError(t, err)
+
+	first := <-messages
+	second := <-messages
+	assert.Equal(t, []byte("1"), first.Key)
+	assert.Equal(t, "images", first.Queue)
+	assert.Equal(t, int64(1), first.Offset)
+	assert.Equal(t, int64(2), second.Offset)
+	assert.NoError(t, q.Ack(ctx, first))
+
+	require.NoError(t, q.Nack(ctx, second))
+	select {
+	case again := <-messages:
+		assert.Equal(t, second, again)
+	case <-time.After(time.Second):
+		t.Fatal("nacked message was not delivered again")
+	}
+}
+
+func TestMemory_PublishRespectsContext(t *testing.T) {
+	q := NewMemory("images", 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := q.Publish(ctx, Message{Key: []byte("1")})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMemory_PublishDropsWhenFull(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	q := NewMemory("images-dlq", 1)
+	q.dropWhenFull = true
+
+	err := q.Publish(ctx, Message{Key: []byte("1")}, Message{Key: []byte("2")})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, q.Len())
+	messages, err := q.Consume(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), (<-messages).Key)
+}
@@ -0,0 +1,228 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/zlog"
+)
+
+// Defaults of the PostgresOptions left at zero.
+const (
+	defaultPollInterval = time.Second
+	defaultLease        = 5 * time.Minute
+	defaultBatchSize    = 10
+)
+
+// PostgresOptions tune the polling of a Postgres queue. Lease is how long a
+// claimed job stays invisible to other consumers; a job that is neither
+// acknowledged nor nacked within it, because its worker died, is delivered
+// again. Zero values use the defaults.
+type PostgresOptions struct {
+	PollInterval time.Duration
+	Lease        time.Duration
+	BatchSize    int
+}
+
+// validate rejects negative options, zero ones fall back to the defaults.
+func (o PostgresOptions) validate() error {
+	if o.PollInterval < 0 {
+		return fmt.Errorf("postgres.go - queue.poll_interval must not be negative, got %s", o.PollInterval)
+	}
+	if o.Lease < 0 {
+		return fmt.Errorf("postgres.go - queue.lease must not be negative, got %s", o.Lease)
+	}
+	if o.BatchSize < 0 {
+		return fmt.Errorf("postgres.go - queue.batch_size must not be negative, got %d", o.BatchSize)
+	}
+
+	return nil
+}
+
+// Postgres is a JobQueue on the job table for deployments without Kafka.
+// Consumers claim jobs with FOR UPDATE SKIP LOCKED, so any number of workers
+// can poll the same queue.
+type Postgres struct {
+	db   *dbpg.DB
+	name string
+	opts PostgresOptions
+}
+
+func NewPostgres(db *dbpg.DB, name string, opts PostgresOptions) *Postgres {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = defaultLease
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+
+	return &Postgres{
+		db:   db,
+		name: name,
+		opts: opts,
+	}
+}
+
+func (p *Postgres) Publish(ctx context.Context, msgs ...Message) error {
+	query := `
+		INSERT INTO job (queue, key, value, headers)
+		VALUES ($1, $2, $3, $4);
+	`
+
+	tx, err := p.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("postgres.go - failed to begin transaction - %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, msg := range msgs {
+		headers, err := json.Marshal(msg.Headers)
+		if err != nil {
+			return fmt.Errorf("postgres.go - failed to marshal headers - %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, query, p.name, msg.Key, msg.Value, headers); err != nil {
+			return fmt.Errorf("postgres.go - failed to insert job - %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("postgres.go - failed to commit transaction - %w", err)
+	}
+
+	return nil
+}
+
+// Consume polls for available jobs every PollInterval until ctx is done.
+func (p *Postgres) Consume(ctx context.Context) (<-chan Message, error) {
+	out := make(chan Message)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(p.opts.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			msgs, err := p.claim(ctx)
+			if err != nil {
+				zlog.Logger.Warn().Err(err).Msg("postgres.go - failed to claim jobs")
+			}
+			for i, msg := range msgs {
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+					p.release(msgs[i:])
+					return
+				}
+			}
+			if len(msgs) == p.opts.BatchSize {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// release makes claimed but undelivered jobs available again instead of
+// leaving them leased.
+func (p *Postgres) release(msgs []Message) {
+	ctx := context.Background()
+	for _, msg := range msgs {
+		if err := p.Nack(ctx, msg); err != nil {
+			zlog.Logger.Warn().Err(err).Int64("job", msg.Offset).Msg("postgres.go - failed to release job")
+		}
+	}
+}
+
+// claim leases up to BatchSize available jobs.
+func (p *Postgres) claim(ctx context.Context) ([]Message, error) {
+	query := `
+		UPDATE job
+		SET available_at = now() + $2 * interval '1 millisecond'
+		WHERE id IN (
+			SELECT id
+			FROM job
+			WHERE queue = $1 AND available_at <= now()
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, key, value, headers, created_at;
+	`
+
+	rows, err := p.db.Master.QueryContext(ctx, query, p.name, p.opts.Lease.Milliseconds(), p.opts.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.go - failed to claim jobs - %w", err)
+	}
+	defer rows.Close()
+
+	msgs := []Message{}
+	for rows.Next() {
+		msg := Message{Queue: p.name}
+		var headers []byte
+		if err := rows.Scan(&msg.Offset, &msg.Key, &msg.Value, &headers, &msg.Time); err != nil {
+			return nil, fmt.Errorf("postgres.go - failed to scan job - %w", err)
+		}
+		if err := json.Unmarshal(headers, &msg.Headers); err != nil {
+			return nil, fmt.Errorf("postgres.go - failed to unmarshal headers - %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres.go - failed to claim jobs - %w", err)
+	}
+
+	return msgs, nil
+}
+
+// Ack deletes the job.
+func (p *Postgres) Ack(ctx context.Context, msg Message) error {
+	query := `
+		DELETE
+		FROM job
+		WHERE id = $1;
+	`
+
+	if _, err := p.db.ExecContext(ctx, query, msg.Offset); err != nil {
+		return fmt.Errorf("postgres.go - failed to delete job - %w", err)
+	}
+
+	return nil
+}
+
+// Nack ends the lease, the job is delivered again on the next poll.
+func (p *Postgres) Nack(ctx context.Context, msg Message) error {
+	query := `
+		UPDATE job
+		SET available_at = now()
+		WHERE id = $1;
+	`
+
+	if _, err := p.db.ExecContext(ctx, query, msg.Offset); err != nil {
+		return fmt.Errorf("postgres.go - failed to release job - %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	if err := p.db.Master.PingContext(ctx); err != nil {
+		return fmt.Errorf("postgres.go - failed to ping database - %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) Close() error {
+	return nil
+}
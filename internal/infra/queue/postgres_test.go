@@ -0,0 +1,180 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wb-go/wbf/config"
+	"github.com/wb-go/wbf/dbpg"
+)
+
+func newTestPostgres(t *testing.T) (*Postgres, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return NewPostgres(&dbpg.DB{Master: db}, "images", PostgresOptions{
+		PollInterval: time.Millisecond,
+		Lease:        time.Minute,
+		BatchSize:    2,
+	}), mock
+}
+
+func TestPostgres_Publish(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockSetup   func(sqlmock.Sqlmock)
+		expectError error
+	}{
+		{
+			name: "success",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO job \(queue, key, value, headers\) VALUES \(\$1, \$2, \$3, \$4\)`).
+					WithArgs("images", []byte("1"), []byte("{}"), []byte(`{"trace-id":"abc"}`)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "db error",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO job`).
+					WillReturnError(errors.New("db error"))
+				mock.ExpectRollback()
+			},
+			expectError: errors.New("postgres.go - failed to insert job - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, mock := newTestPostgres(t)
+			tt.mockSetup(mock)
+
+			err := q.Publish(context.Background(), Message{
+				Key:     []byte("1"),
+				Value:   []byte("{}"),
+				Headers: map[string]string{"trace-id": "abc"},
+			})
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPostgres_Claim(t *testing.T) {
+	createdAt := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	claimQuery := `UPDATE job SET available_at = now\(\) \+ \$2 \* interval '1 millisecond' WHERE id IN \( ` +
+		`SELECT id FROM job WHERE queue = \$1 AND available_at <= now\(\) ORDER BY id LIMIT \$3 FOR UPDATE SKIP LOCKED \) ` +
+		`RETURNING id, key, value, headers, created_at`
+
+	q, mock := newTestPostgres(t)
+	mock.ExpectQuery(claimQuery).
+		WithArgs("images", int64(60000), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key", "value", "headers", "created_at"}).
+			AddRow(5, []byte("1"), []byte("{}"), []byte(`{"trace-id":"abc"}`), createdAt))
+
+	msgs, err := q.claim(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []Message{{
+		Key:     []byte("1"),
+		Value:   []byte("{}"),
+		Headers: map[string]string{"trace-id": "abc"},
+		Queue:   "images",
+		Offset:  5,
+		Time:    createdAt,
+	}}, msgs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgres_AckNack(t *testing.T) {
+	q, mock := newTestPostgres(t)
+	mock.ExpectExec(`DELETE FROM job WHERE id = \$1`).
+		WithArgs(int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE job SET available_at = now\(\) WHERE id = \$1`).
+		WithArgs(int64(6)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, q.Ack(context.Background(), Message{Offset: 5}))
+	assert.NoError(t, q.Nack(context.Background(), Message{Offset: 6}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgres_Release(t *testing.T) {
+	q, mock := newTestPostgres(t)
+	mock.ExpectExec(`UPDATE job SET available_at = now\(\) WHERE id = \$1`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE job SET available_at = now\(\) WHERE id = \$1`).
+		WithArgs(int64(8)).
+		WillReturnError(errors.New("connection reset"))
+
+	q.release([]Message{{Offset: 7}, {Offset: 8}})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNew_PostgresOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		pollInterval time.Duration
+		lease        time.Duration
+		batchSize    int
+		expected     PostgresOptions
+		expectError  bool
+	}{
+		{
+			name:     "unset options use the defaults",
+			expected: PostgresOptions{PollInterval: defaultPollInterval, Lease: defaultLease, BatchSize: defaultBatchSize},
+		},
+		{
+			name:         "configured",
+			pollInterval: 200 * time.Millisecond, lease: time.Minute, batchSize: 5,
+			expected: PostgresOptions{PollInterval: 200 * time.Millisecond, Lease: time.Minute, BatchSize: 5},
+		},
+		{name: "negative poll interval", pollInterval: -time.Second, expectError: true},
+		{name: "negative lease", lease: -time.Second, expectError: true},
+		{name: "negative batch size", batchSize: -1, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.SetDefault("queue.backend", BackendPostgres)
+			if tt.pollInterval != 0 {
+				cfg.SetDefault("queue.poll_interval", tt.pollInterval)
+			}
+			if tt.lease != 0 {
+				cfg.SetDefault("queue.lease", tt.lease)
+			}
+			if tt.batchSize != 0 {
+				cfg.SetDefault("queue.batch_size", tt.batchSize)
+			}
+
+			q, err := New(cfg, &dbpg.DB{}, "images", "")
+
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.IsType(t, &Postgres{}, q)
+			assert.Equal(t, tt.expected, q.(*Postgres).opts)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Package queue delivers image jobs from the API to the workers. JobQueue is
+// implemented on top of Kafka, Postgres and an in-process channel; every
+// backend delivers a job at least once.
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/config"
+	"github.com/wb-go/wbf/dbpg"
+)
+
+const (
+	BackendKafka    = "kafka"
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+// Message is a job. Queue, Partition and Offset locate the message in its
+// backend and are filled in on consume; they are what Ack and Nack act on.
+type Message struct {
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Queue     string
+	Partition int
+	Offset    int64
+	Time      time.Time
+}
+
+// JobQueue publishes and consumes jobs of one named queue.
+//
+// Every consumed message must be passed to either Ack, once its outcome is
+// stored, or Nack, when it should be delivered again. When a nacked message
+// comes back depends on the backend.
+type JobQueue interface {
+	Publish(context.Context, ...Message) error
+	Consume(context.Context) (<-chan Message, error)
+	Ack(context.Context, Message) error
+	Nack(context.Context, Message) error
+	// Ping reports whether the backend is reachable.
+	Ping(context.Context) error
+	Close() error
+}
+
+var (
+	memoryMu     sync.Mutex
+	memoryQueues = map[string]*Memory{}
+)
+
+// New creates the queue.backend configured queue called name. group is the
+// Kafka consumer group. Memory queues are shared by name within the process,
+// so they only connect an API and workers running in the same binary.
+func New(cfg *config.Config, db *dbpg.DB, name, group string) (JobQueue, error) {
+	switch backend := cfg.GetString("queue.backend"); backend {
+	case "", BackendKafka:
+		return NewKafka(cfg.GetStringSlice("kafka.brokers"), name, group), nil
+
+	case BackendPostgres:
+		opts := PostgresOptions{
+			PollInterval: cfg.GetDuration("queue.poll_interval"),
+			Lease:        cfg.GetDuration("queue.lease"),
+			BatchSize:    cfg.GetInt("queue.batch_size"),
+		}
+		if err := opts.validate(); err != nil {
+			return nil, err
+		}
+		return NewPostgres(db, name, opts), nil
+
+	case BackendMemory:
+		return memoryQueue(name, cfg.GetInt("queue.memory_size"), false), nil
+
+	default:
+		return nil, fmt.Errorf("queue.go - unknown queue backend %q", backend)
+	}
+}
+
+// NewDeadLetter creates the dead-letter queue called name like New. Nothing
+// consumes a memory dead-letter queue, so once it holds queue.memory_size
+// messages further ones are dropped and logged instead of blocking the
+// workers.
+func NewDeadLetter(cfg *config.Config, db *dbpg.DB, name, group string) (JobQueue, error) {
+	if cfg.GetString("queue.backend") == BackendMemory {
+		return memoryQueue(name, cfg.GetInt("queue.memory_size"), true), nil
+	}
+
+	return New(cfg, db, name, group)
+}
+
+func memoryQueue(name string, size int, dropWhenFull bool) *Memory {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	q, ok := memoryQueues[name]
+	if !ok {
+		q = NewMemory(name, size)
+		q.dropWhenFull = dropWhenFull
+		memoryQueues[name] = q
+	}
+
+	return q
+}
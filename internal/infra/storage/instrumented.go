@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+)
+
+// Instrumented records the latency of every call of the wrapped store. A
+// missing object is not counted as a failure.
+type Instrumented struct {
+	store ObjectStore
+}
+
+func Instrument(store ObjectStore) *Instrumented {
+	return &Instrumented{store: store}
+}
+
+func (i *Instrumented) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	start := time.Now()
+	err := i.store.Put(ctx, key, r, size, contentType)
+	observe("put", start, err)
+	return err
+}
+
+func (i *Instrumented) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	start := time.Now()
+	r, info, err := i.store.Get(ctx, key)
+	observe("get", start, err)
+	return r, info, err
+}
+
+func (i *Instrumented) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	start := time.Now()
+	info, err := i.store.Stat(ctx, key)
+	observe("stat", start, err)
+	return info, err
+}
+
+func (i *Instrumented) Delete(ctx context.Context, keys ...string) error {
+	start := time.Now()
+	err := i.store.Delete(ctx, keys...)
+	observe("delete", start, err)
+	return err
+}
+
+func (i *Instrumented) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	start := time.Now()
+	objects, err := i.store.List(ctx, prefix)
+	observe("list", start, err)
+	return objects, err
+}
+
+func (i *Instrumented) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	start := time.Now()
+	url, err := i.store.PresignGet(ctx, key, expires)
+	if !errors.Is(err, ErrPresignNotSupported) {
+		observe("presign_get", start, err)
+	}
+	return url, err
+}
+
+func (i *Instrumented) Ping(ctx context.Context) error {
+	return i.store.Ping(ctx)
+}
+
+func observe(method string, start time.Time, err error) {
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+	metrics.StorageDuration.WithLabelValues(method, metrics.Result(err)).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	metaSuffix = ".meta"
+)
+
+// Local keeps objects as files below a root directory. The content type and
+// checksum of every object live in a ".meta" file next to it.
+type Local struct {
+	root string
+}
+
+type localMeta struct {
+	ContentType string `json:"content_type"`
+	ETag        string `json:"etag"`
+}
+
+func NewLocal(root string) (*Local, error) {
+	if root == "" {
+		return nil, fmt.Errorf("local.go - storage.local_dir required")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("local.go - failed to create %s - %w", root, err)
+	}
+
+	return &Local{root: root}, nil
+}
+
+// Put writes into a temporary file first and renames it, so readers never
+// see a partially written object.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("local.go - failed to create directory for %s - %w", key, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("local.go - failed to create temporary file for %s - %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("local.go - failed to write %s - %w", key, err)
+	}
+	if size >= 0 && n != size {
+		return fmt.Errorf("local.go - failed to write %s - got %d bytes, expected %d", key, n, size)
+	}
+
+	meta, err := json.Marshal(localMeta{ContentType: contentType, ETag: hex.EncodeToString(hash.Sum(nil))})
+	if err != nil {
+		return fmt.Errorf("local.go - failed to marshal metadata of %s - %w", key, err)
+	}
+	if err := os.WriteFile(path+metaSuffix, meta, 0o644); err != nil {
+		return fmt.Errorf("local.go - failed to write metadata of %s - %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("local.go - failed to rename %s - %w", key, err)
+	}
+
+	return nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	info, err := l.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, _ := l.path(key)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("local.go - failed to open %s - %w", key, mapFSError(err))
+	}
+
+	return file, info, nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return l.stat(key, path)
+}
+
+func (l *Local) stat(key, path string) (*ObjectInfo, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("local.go - failed to stat %s - %w", key, mapFSError(err))
+	}
+	if fi.IsDir() {
+		return nil, fmt.Errorf("local.go - failed to stat %s - %w", key, ErrNotFound)
+	}
+
+	var meta localMeta
+	data, err := os.ReadFile(path + metaSuffix)
+	if err == nil {
+		err = json.Unmarshal(data, &meta)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("local.go - failed to read metadata of %s - %w", key, err)
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		ContentType:  meta.ContentType,
+		Size:         fi.Size(),
+		ETag:         meta.ETag,
+		LastModified: fi.ModTime(),
+	}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		path, err := l.path(key)
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{path, path + metaSuffix} {
+			if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("local.go - failed to remove %s - %w", key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// List walks only the directory the prefix points into, so listing the
+// variants of one image doesn't read the whole tree. A directory that doesn't
+// exist lists nothing.
+func (l *Local) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects := []ObjectInfo{}
+	dir := l.root
+	if prefixDir := filepath.Dir(filepath.FromSlash(prefix)); prefixDir != "." {
+		var err error
+		if dir, err = l.path(filepath.ToSlash(prefixDir)); err != nil {
+			return nil, err
+		}
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, metaSuffix) || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := l.stat(key, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, *info)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("local.go - failed to list %s - %w", prefix, err)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	return objects, nil
+}
+
+func (l *Local) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+// Ping checks that the root is still a directory.
+func (l *Local) Ping(ctx context.Context) error {
+	fi, err := os.Stat(l.root)
+	if err != nil {
+		return fmt.Errorf("local.go - failed to stat %s - %w", l.root, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("local.go - %s is not a directory", l.root)
+	}
+
+	return nil
+}
+
+// path maps key below the root and refuses keys escaping it.
+func (l *Local) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) ||
+		strings.HasSuffix(clean, metaSuffix) {
+		return "", fmt.Errorf("local.go - invalid key %q", key)
+	}
+
+	return filepath.Join(l.root, clean), nil
+}
+
+func mapFSError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+
+	return err
+}
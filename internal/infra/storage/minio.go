@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go"
+)
+
+const (
+	errCodeNoSuchKey = "NoSuchKey"
+)
+
+type Minio struct {
+	Minio  *minio.Client
+	bucket string
+}
+
+func NewMinio(endpoint, user, password, bucketName, location string, ssl bool) (*Minio, error) {
+	minioClient, err := minio.New(endpoint, user, password, ssl)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := minioClient.BucketExists(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		err = minioClient.MakeBucket(bucketName, location)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Minio{
+		Minio:  minioClient,
+		bucket: bucketName,
+	}, nil
+}
+
+func (m *Minio) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	putObjectOptions := minio.PutObjectOptions{
+		ContentType: contentType,
+	}
+	if _, err := m.Minio.PutObjectWithContext(ctx, m.bucket, key, r, size, putObjectOptions); err != nil {
+		return fmt.Errorf("minio.go - failed to put %s - %w", key, err)
+	}
+
+	return nil
+}
+
+func (m *Minio) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	object, err := m.Minio.GetObjectWithContext(ctx, m.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("minio.go - failed to get %s - %w", key, mapError(err))
+	}
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, nil, fmt.Errorf("minio.go - failed to stat %s - %w", key, mapError(err))
+	}
+
+	return object, toObjectInfo(info), nil
+}
+
+func (m *Minio) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := m.Minio.StatObject(m.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("minio.go - failed to stat %s - %w", key, mapError(err))
+	}
+
+	return toObjectInfo(info), nil
+}
+
+// Delete removes keys in one batch. The error channel is drained completely,
+// the first error is returned.
+func (m *Minio) Delete(ctx context.Context, keys ...string) error {
+	objectsCh := make(chan string)
+	go func() {
+		defer close(objectsCh)
+		for _, key := range keys {
+			objectsCh <- key
+		}
+	}()
+
+	var errRemove error
+	for removeErr := range m.Minio.RemoveObjectsWithContext(ctx, m.bucket, objectsCh) {
+		if errRemove == nil {
+			errRemove = fmt.Errorf("minio.go - failed to remove %s - %w", removeErr.ObjectName, removeErr.Err)
+		}
+	}
+
+	return errRemove
+}
+
+func (m *Minio) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	objects := []ObjectInfo{}
+	for object := range m.Minio.ListObjectsV2(m.bucket, prefix, true, doneCh) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("minio.go - failed to list %s - %w", prefix, object.Err)
+		}
+		objects = append(objects, *toObjectInfo(object))
+	}
+
+	return objects, nil
+}
+
+func (m *Minio) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	u, err := m.Minio.PresignedGetObject(m.bucket, key, expires, url.Values{})
+	if err != nil {
+		return "", fmt.Errorf("minio.go - failed to presign %s - %w", key, err)
+	}
+
+	return u.String(), nil
+}
+
+// Ping checks that the bucket is accessible.
+func (m *Minio) Ping(ctx context.Context) error {
+	exists, err := m.Minio.BucketExists(m.bucket)
+	if err != nil {
+		return fmt.Errorf("minio.go - failed to access bucket %s - %w", m.bucket, err)
+	}
+	if !exists {
+		return fmt.Errorf("minio.go - bucket %s does not exist", m.bucket)
+	}
+
+	return nil
+}
+
+func toObjectInfo(info minio.ObjectInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Key:          info.Key,
+		ContentType:  info.ContentType,
+		Size:         info.Size,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}
+}
+
+// mapError turns the S3 "no such key" error into ErrNotFound.
+func mapError(err error) error {
+	var errResp minio.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Code == errCodeNoSuchKey {
+		return ErrNotFound
+	}
+
+	return err
+}
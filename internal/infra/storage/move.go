@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Move copies the object at from to to and then deletes from. Stores have no
+// server-side copy in common, so the object passes through the process.
+func Move(ctx context.Context, store ObjectStore, from, to string) error {
+	r, info, err := store.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := store.Put(ctx, to, r, info.Size, info.ContentType); err != nil {
+		return fmt.Errorf("move.go - failed to copy %s to %s - %w", from, to, err)
+	}
+	if err := store.Delete(ctx, from); err != nil {
+		return fmt.Errorf("move.go - failed to delete %s - %w", from, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+// Package storage keeps originals and their variants. ObjectStore is
+// implemented on top of MinIO, a local directory and memory.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/config"
+
+	"github.com/google/uuid"
+)
+
+const (
+	BackendMinio  = "minio"
+	BackendLocal  = "local"
+	BackendMemory = "memory"
+
+	originalsPrefix = "originals"
+	variantsPrefix  = "variants"
+)
+
+var (
+	ErrNotFound            = errors.New("object not found")
+	ErrPresignNotSupported = errors.New("presigned urls are not supported")
+)
+
+type ObjectInfo struct {
+	Key          string
+	ContentType  string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+// ObjectStore stores immutable objects under slash separated keys. Get and
+// Stat return ErrNotFound for missing keys, Delete ignores them.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	Delete(ctx context.Context, keys ...string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// PresignGet returns a URL to download key without credentials, or
+	// ErrPresignNotSupported.
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+	// Ping reports whether the store is reachable and usable.
+	Ping(ctx context.Context) error
+}
+
+// OriginalKey is the object key of the uploaded picture as it was received.
+func OriginalKey(id uuid.UUID) string {
+	return fmt.Sprintf("%s/%s", originalsPrefix, id)
+}
+
+// VariantsPrefix is the common prefix of all variants derived from one image.
+func VariantsPrefix(id uuid.UUID) string {
+	return fmt.Sprintf("%s/%s/", variantsPrefix, id)
+}
+
+// VariantKey is the object key of a named variant derived from the original.
+func VariantKey(id uuid.UUID, variant string) string {
+	return VariantsPrefix(id) + variant
+}
+
+// LegacyOriginalKey and LegacyVariantsPrefix are the keys of images stored
+// before images had public ids, under their internal serial id.
+func LegacyOriginalKey(id int64) string {
+	return fmt.Sprintf("%s/%d", originalsPrefix, id)
+}
+
+func LegacyVariantsPrefix(id int64) string {
+	return fmt.Sprintf("%s/%d/", variantsPrefix, id)
+}
+
+// BaselineKey is the key of images stored before originals and variants were
+// split: a single object under the bare serial id, holding the original until
+// the worker replaced it with the processed result.
+func BaselineKey(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+var (
+	memoryOnce  sync.Once
+	memoryStore *Memory
+)
+
+// New creates the storage.backend configured store, instrumented with
+// metrics. There is one memory store per process, so an API and workers in
+// the same binary share it.
+func New(cfg *config.Config) (ObjectStore, error) {
+	store, err := newStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return Instrument(store), nil
+}
+
+func newStore(cfg *config.Config) (ObjectStore, error) {
+	switch backend := cfg.GetString("storage.backend"); backend {
+	case "", BackendMinio:
+		return NewMinio(
+			cfg.GetString("MINIO_HOST")+":"+cfg.GetString("MINIO_PORT"),
+			cfg.GetString("MINIO_ROOT_USER"), cfg.GetString("MINIO_ROOT_PASSWORD"),
+			cfg.GetString("s3.bucket_name"), cfg.GetString("s3.location"),
+			cfg.GetBool("MINIO_SSL"),
+		)
+
+	case BackendLocal:
+		return NewLocal(cfg.GetString("storage.local_dir"))
+
+	case BackendMemory:
+		memoryOnce.Do(func() {
+			memoryStore = NewMemory()
+		})
+		return memoryStore, nil
+
+	default:
+		return nil, fmt.Errorf("storage.go - unknown storage backend %q", backend)
+	}
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	imageID = uuid.MustParse("0193f1c2-7a40-7cc1-9a3e-2f1d0c4b5a61")
+	otherID = uuid.MustParse("0193f1c2-7a40-7cc1-9a3e-2f1d0c4b5a62")
+	missing = uuid.MustParse("0193f1c2-7a40-7cc1-9a3e-2f1d0c4b5a63")
+)
+
+func stores(t *testing.T) map[string]ObjectStore {
+	local, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	return map[string]ObjectStore{
+		"memory": NewMemory(),
+		"local":  local,
+	}
+}
+
+func TestObjectStore(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, store.Put(ctx, OriginalKey(imageID), strings.NewReader("original"), 8, "image/png"))
+			require.NoError(t, store.Put(ctx, VariantKey(imageID, "small"), strings.NewReader("small"), 5, "image/jpeg"))
+			require.NoError(t, store.Put(ctx, VariantKey(imageID, "large"), strings.NewReader("large!"), -1, "image/jpeg"))
+			require.NoError(t, store.Put(ctx, VariantKey(otherID, "small"), strings.NewReader("other"), 5, "image/jpeg"))
+
+			r, info, err := store.Get(ctx, OriginalKey(imageID))
+			require.NoError(t, err)
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			assert.Equal(t, "original", string(data))
+			assert.Equal(t, "image/png", info.ContentType)
+			assert.Equal(t, int64(8), info.Size)
+			assert.NotEmpty(t, info.ETag)
+
+			info, err = store.Stat(ctx, VariantKey(imageID, "large"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), info.Size)
+
+			objects, err := store.List(ctx, VariantsPrefix(imageID))
+			require.NoError(t, err)
+			keys := []string{}
+			for _, object := range objects {
+				keys = append(keys, object.Key)
+			}
+			assert.Equal(t, []string{VariantKey(imageID, "large"), VariantKey(imageID, "small")}, keys)
+
+			require.NoError(t, store.Delete(ctx, OriginalKey(imageID), VariantKey(imageID, "small"), OriginalKey(missing)))
+			_, _, err = store.Get(ctx, OriginalKey(imageID))
+			assert.True(t, errors.Is(err, ErrNotFound))
+			_, err = store.Stat(ctx, VariantKey(imageID, "small"))
+			assert.True(t, errors.Is(err, ErrNotFound))
+
+			_, err = store.PresignGet(ctx, VariantKey(imageID, "large"), 0)
+			assert.True(t, errors.Is(err, ErrPresignNotSupported))
+		})
+	}
+}
+
+func TestObjectStorePutSizeMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			err := store.Put(ctx, OriginalKey(imageID), strings.NewReader("short"), 100, "image/png")
+			assert.Error(t, err)
+			_, err = store.Stat(ctx, OriginalKey(imageID))
+			assert.True(t, errors.Is(err, ErrNotFound))
+		})
+	}
+}
+
+func TestLocalInvalidKey(t *testing.T) {
+	local, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "empty", key: ""},
+		{name: "parent", key: "../escape"},
+		{name: "nested parent", key: "originals/../../escape"},
+		{name: "absolute", key: "/etc/passwd"},
+		{name: "metadata", key: "originals/1.meta"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := local.Put(context.Background(), tt.key, strings.NewReader("x"), 1, "")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMove(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, store.Put(ctx, LegacyOriginalKey(1), strings.NewReader("original"), 8, "image/png"))
+
+			require.NoError(t, Move(ctx, store, LegacyOriginalKey(1), OriginalKey(imageID)))
+
+			_, err := store.Stat(ctx, LegacyOriginalKey(1))
+			assert.True(t, errors.Is(err, ErrNotFound))
+			info, err := store.Stat(ctx, OriginalKey(imageID))
+			require.NoError(t, err)
+			assert.Equal(t, int64(8), info.Size)
+			assert.Equal(t, "image/png", info.ContentType)
+
+			err = Move(ctx, store, LegacyOriginalKey(1), OriginalKey(imageID))
+			assert.True(t, errors.Is(err, ErrNotFound))
+		})
+	}
+}
+
+func TestLocalList(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, local.Put(ctx, OriginalKey(imageID), strings.NewReader("original"), 8, "image/png"))
+	require.NoError(t, local.Put(ctx, VariantKey(imageID, "small"), strings.NewReader("small"), 5, "image/jpeg"))
+	require.NoError(t, local.Put(ctx, VariantKey(otherID, "small"), strings.NewReader("other"), 5, "image/jpeg"))
+
+	tests := []struct {
+		name         string
+		prefix       string
+		expectedKeys []string
+		expectError  bool
+	}{
+		{name: "everything", prefix: "", expectedKeys: []string{OriginalKey(imageID), VariantKey(imageID, "small"), VariantKey(otherID, "small")}},
+		{name: "directory", prefix: "originals/", expectedKeys: []string{OriginalKey(imageID)}},
+		{name: "one image", prefix: VariantsPrefix(imageID), expectedKeys: []string{VariantKey(imageID, "small")}},
+		{name: "without trailing slash", prefix: "variants/" + imageID.String(), expectedKeys: []string{VariantKey(imageID, "small")}},
+		{name: "missing directory", prefix: VariantsPrefix(missing), expectedKeys: []string{}},
+		{name: "missing top directory", prefix: "thumbnails/", expectedKeys: []string{}},
+		{name: "outside the root", prefix: "../escape/", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := local.List(ctx, tt.prefix)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			keys := []string{}
+			for _, object := range objects {
+				keys = append(keys, object.Key)
+			}
+			assert.Equal(t, tt.expectedKeys, keys)
+		})
+	}
+}
+
+func TestMoveBaselineKey(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, store.Put(ctx, BaselineKey(1), strings.NewReader("result"), 6, "image/jpeg"))
+
+			require.NoError(t, Move(ctx, store, BaselineKey(1), VariantKey(imageID, "processed")))
+
+			_, err := store.Stat(ctx, BaselineKey(1))
+			assert.True(t, errors.Is(err, ErrNotFound))
+			info, err := store.Stat(ctx, VariantKey(imageID, "processed"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), info.Size)
+			assert.Equal(t, "image/jpeg", info.ContentType)
+		})
+	}
+}
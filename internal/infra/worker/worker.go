@@ -5,12 +5,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	myKafka "github.com/avraam311/image-processor/internal/infra/kafka"
-	myMinio "github.com/avraam311/image-processor/internal/infra/minio"
+	handlers "github.com/avraam311/image-processor/internal/infra/handlers/images"
+	"github.com/avraam311/image-processor/internal/infra/queue"
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/metrics"
 	"github.com/avraam311/image-processor/internal/models"
 	"github.com/avraam311/image-processor/internal/repository/images"
 
@@ -18,52 +22,150 @@ import (
 	"github.com/wb-go/wbf/retry"
 	"github.com/wb-go/wbf/zlog"
 
-	"github.com/minio/minio-go"
-	"github.com/segmentio/kafka-go"
+	"github.com/google/uuid"
 )
 
 const (
-	imageStatusProcessed = "processed"
-	imageFormat          = "image/jpeg"
+	defaultVariant = "processed"
 )
 
+// Error codes stored on images whose processing failed.
+const (
+	errCodeInvalidKey       = "invalid_key"
+	errCodeInvalidMessage   = "invalid_message"
+	errCodeStorageFailed    = "storage_failed"
+	errCodeDatabaseFailed   = "database_failed"
+	errCodeDecodeFailed     = "decode_failed"
+	errCodeEncodeFailed     = "encode_failed"
+	errCodeProcessingFailed = "processing_failed"
+)
+
+// Results of the jobs_total metric.
+const (
+	jobResultProcessed    = "processed"
+	jobResultDeduplicated = "deduplicated"
+	jobResultFailed       = "failed"
+	jobResultDeadLettered = "dead_lettered"
+	jobResultRetried      = "retried"
+	jobResultReturned     = "returned"
+	jobResultDiscarded    = "discarded"
+)
+
+// errImageGone is returned for a job whose image was deleted, there is
+// nothing left to process and the job is dropped.
+var errImageGone = errors.New("image no longer exists")
+
+// deadLetterCodes are failures caused by the message itself. Delivering such
+// a message again fails the same way, so it is moved to the dead-letter topic.
+var deadLetterCodes = map[string]bool{
+	errCodeInvalidKey:     true,
+	errCodeInvalidMessage: true,
+	errCodeDecodeFailed:   true,
+}
+
 type Handler interface {
-	ProcessImage([]byte, string) ([]byte, error)
+	ProcessImage([]byte, []models.Operation) (*models.ProcessedImage, error)
 }
 
 type Repository interface {
-	ChangeImageStatus(context.Context, uint, string) error
-	CheckImage(context.Context, uint) error
+	CompleteImage(context.Context, uuid.UUID, models.ImageInfo, models.ImageInfo) error
+	CheckImage(context.Context, uuid.UUID) (*models.ImageRecord, error)
+	FailImage(context.Context, uuid.UUID, string, string) error
+	StartAttempt(context.Context, uuid.UUID) (int, error)
+	RecordError(context.Context, uuid.UUID, string) error
+	ShareResult(context.Context, uuid.UUID) error
+	ObjectInUse(context.Context, uuid.UUID) (bool, error)
 }
 
 type Worker struct {
-	cons   *myKafka.Kafka
-	cfg    *config.Config
-	s3     *myMinio.Minio
-	handIm Handler
-	repo   Repository
+	queue       queue.JobQueue
+	dlq         queue.JobQueue
+	cfg         *config.Config
+	store       storage.ObjectStore
+	handIm      Handler
+	repo        Repository
+	retry       retry.Strategy
+	maxAttempts int
+	inFlight    atomic.Int64
+	consuming   atomic.Bool
+	lastSuccess atomic.Int64
 }
 
-func New(cons *myKafka.Kafka, cfg *config.Config, s3 *myMinio.Minio, handIm Handler, repo Repository) *Worker {
+// Status is the state of a worker reported by the health endpoints.
+type Status struct {
+	Consuming     bool       `json:"consuming"`
+	InFlight      int64      `json:"in_flight"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+}
+
+// jobError carries the error code stored on the image when a job fails.
+// Transient errors, like an unreachable database or storage, are retried
+// while the attempt budget lasts; all others fail the image right away.
+type jobError struct {
+	code      string
+	err       error
+	transient bool
+}
+
+func (e *jobError) Error() string {
+	return fmt.Sprintf("%s: %s", e.code, e.err)
+}
+
+func (e *jobError) Unwrap() error {
+	return e.err
+}
+
+func New(jobs queue.JobQueue, dlq queue.JobQueue, cfg *config.Config, store storage.ObjectStore, handIm Handler, repo Repository) *Worker {
 	return &Worker{
-		cons:   cons,
+		queue:  jobs,
+		dlq:    dlq,
 		cfg:    cfg,
-		s3:     s3,
+		store:  store,
 		handIm: handIm,
 		repo:   repo,
+		retry: retry.Strategy{
+			Attempts: cfg.GetInt("retry.attempts"),
+			Delay:    cfg.GetDuration("retry.delay"),
+			Backoff:  cfg.GetFloat64("retry.backoff"),
+		},
+		maxAttempts: cfg.GetInt("worker.max_attempts"),
 	}
 }
 
+// Run processes jobs until ctx is done and then drains: no new message is
+// fetched, jobs in flight get worker.shutdown_timeout to finish and are
+// acknowledged as usual, the ones still running after that are nacked. Run
+// returns once every job is handed back, so the queue and the database may
+// be closed right after.
 func (w *Worker) Run(ctx context.Context) {
-	consChan := make(chan kafka.Message)
-	retryStrategy := retry.Strategy{
-		Attempts: w.cfg.GetInt("retry.attempts"),
-		Delay:    w.cfg.GetDuration("retry.delay"),
-		Backoff:  w.cfg.GetFloat64("retry.backoff"),
+	messages, err := w.queue.Consume(ctx)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("worker.go - failed to consume queue")
+		return
 	}
 
+	w.consuming.Store(true)
+	defer w.consuming.Store(false)
+
+	// Jobs outlive ctx by up to the shutdown timeout.
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+	drained := make(chan struct{})
 	go func() {
-		w.cons.Consume(ctx, consChan, retryStrategy)
+		select {
+		case <-drained:
+			return
+		case <-ctx.Done():
+		}
+		w.consuming.Store(false)
+		timeout := w.cfg.GetDuration("worker.shutdown_timeout")
+		zlog.Logger.Info().Int64("in_flight", w.inFlight.Load()).Dur("timeout", timeout).Msg("worker.go - draining jobs")
+		select {
+		case <-drained:
+		case <-time.After(timeout):
+			zlog.Logger.Warn().Int64("in_flight", w.inFlight.Load()).Msg("worker.go - shutdown timeout exceeded, returning jobs to the queue")
+			cancelJobs()
+		}
 	}()
 
 	var wg sync.WaitGroup
@@ -72,85 +174,297 @@ func (w *Worker) Run(ctx context.Context) {
 	for i := 0; i < workerCount; i++ {
 		go func(id int) {
 			defer wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case msg := <-consChan:
-					imageID, err := strconv.Atoi(string(msg.Key))
-					if err != nil {
-						zlog.Logger.Warn().Err(err).Msg("worker.go - failed to convert msg.Key into int")
-						continue
-					}
-
-					err = w.repo.CheckImage(ctx, uint(imageID))
-					if err != nil {
-						if errors.Is(err, images.ErrImageNotFound) {
-							err := w.s3.Minio.RemoveObject(w.cfg.GetString("s3.bucket_name"), string(msg.Key))
-							if err != nil {
-								zlog.Logger.Warn().Err(err).Msg("worker.go - no image to process, failed to remove image from s3")
-								continue
-							}
-
-							zlog.Logger.Warn().Err(err).Msg("worker.go - no image to process")
-							continue
-						}
-					}
-
-					imProc := models.ImageKafka{}
-					err = json.Unmarshal(msg.Value, &imProc)
-					if err != nil {
-						zlog.Logger.Warn().Err(err).Msg("worker.go - failed to unmarshal message into struct")
-						continue
-					}
-
-					s3Object, err := w.s3.Minio.GetObject(w.cfg.GetString("s3.bucket_name"), string(msg.Key), minio.GetObjectOptions{})
-					if err != nil {
-						zlog.Logger.Warn().Err(err).Msg("worker.go - failed to get image from s3")
-						continue
-					}
-					defer s3Object.Close()
-					buf := new(bytes.Buffer)
-					if _, err = io.Copy(buf, s3Object); err != nil {
-						zlog.Logger.Warn().Err(err).Msg("worker.go - failed to copy s3Object into buffer")
-						continue
-					}
-					imageBytes := buf.Bytes()
-					image := models.Image{}
-					err = json.Unmarshal(imageBytes, &image)
-					if err != nil {
-						zlog.Logger.Warn().Err(err).Msg("worker.go - failed to unmarshal buf image into struct")
-						continue
-					}
-
-					processedImage, err := w.handIm.ProcessImage(image.Image, imProc.Processing)
-					if err != nil {
-						zlog.Logger.Warn().Err(err).Msg("worker.go - failed to process image")
-						continue
-					}
-
-					objectName := string(msg.Key)
-					imageAsReader := bytes.NewReader(processedImage)
-					size := int64(len(processedImage))
-					putObjectOptions := minio.PutObjectOptions{
-						ContentType: imageFormat,
-					}
-					_, err = w.s3.Minio.PutObject(w.cfg.GetString("s3.bucket_name"), objectName, imageAsReader, size, putObjectOptions)
-					if err != nil {
-						zlog.Logger.Warn().Err(err).Msg("worker.go - failed to put processed image into s3")
-						continue
-					}
-
-					err = w.repo.ChangeImageStatus(ctx, (uint(imageID)), imageStatusProcessed)
-					if err != nil {
-						zlog.Logger.Warn().Err(err).Msg("worker.go - failed to change image status")
-						continue
-					}
-					zlog.Logger.Info().Interface("image", msg).Msg("image is processed")
+			for msg := range messages {
+				if ctx.Err() != nil {
+					// Fetched while the shutdown started.
+					w.nack(ctx, msg)
+					continue
 				}
+				if !msg.Time.IsZero() {
+					metrics.QueueLag.Observe(time.Since(msg.Time).Seconds())
+				}
+				start := time.Now()
+				w.inFlight.Add(1)
+				metrics.JobsInFlight.Inc()
+				w.handleMessage(jobCtx, msg)
+				metrics.JobsInFlight.Dec()
+				w.inFlight.Add(-1)
+				metrics.JobDuration.Observe(time.Since(start).Seconds())
 			}
 		}(i)
 	}
 
 	wg.Wait()
+	close(drained)
+	zlog.Logger.Info().Msg("worker.go - jobs drained")
+}
+
+func (w *Worker) Status() Status {
+	status := Status{
+		Consuming: w.consuming.Load(),
+		InFlight:  w.inFlight.Load(),
+	}
+	if last := w.lastSuccess.Load(); last != 0 {
+		lastSuccessAt := time.Unix(0, last).UTC()
+		status.LastSuccessAt = &lastSuccessAt
+	}
+
+	return status
+}
+
+// Ready fails unless the worker consumes the queue.
+func (w *Worker) Ready(ctx context.Context) error {
+	if !w.consuming.Load() {
+		return errors.New("worker.go - not consuming")
+	}
+
+	return nil
+}
+
+// handleMessage processes one job and acknowledges it once the outcome is
+// stored: the variant together with the processed status, or the failed
+// status and, for poison messages, a copy in the dead-letter queue. A job
+// whose outcome could not be stored is nacked to be delivered again.
+func (w *Worker) handleMessage(ctx context.Context, msg queue.Message) {
+	imageID, err := uuid.ParseBytes(msg.Key)
+	if err != nil {
+		jobErr := &jobError{code: errCodeInvalidKey, err: fmt.Errorf("failed to parse msg.Key as image id - %w", err)}
+		zlog.Logger.Warn().Err(jobErr).Msg("worker.go - invalid message key")
+		if !w.deadLetter(ctx, msg, jobErr, 0) {
+			w.nack(ctx, msg)
+			return
+		}
+		w.ack(ctx, msg)
+		return
+	}
+
+	if w.shareResult(ctx, imageID) {
+		zlog.Logger.Info().Stringer("image", imageID).Msg("image shares the result of an identical image")
+		metrics.Jobs.WithLabelValues(jobResultDeduplicated).Inc()
+		w.lastSuccess.Store(time.Now().UnixNano())
+		w.ack(ctx, msg)
+		return
+	}
+
+	attempt, err := w.runJob(ctx, imageID, msg.Value)
+	if errors.Is(err, errImageGone) {
+		zlog.Logger.Warn().Stringer("image", imageID).Msg("worker.go - no image to process")
+		metrics.Jobs.WithLabelValues(jobResultDiscarded).Inc()
+		w.ack(ctx, msg)
+		return
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			// Shutting down, the job is delivered again.
+			w.nack(ctx, msg)
+			return
+		}
+		if !w.failImage(ctx, imageID, err) {
+			w.nack(ctx, msg)
+			return
+		}
+		metrics.Jobs.WithLabelValues(jobResultFailed).Inc()
+		var jobErr *jobError
+		if errors.As(err, &jobErr) && deadLetterCodes[jobErr.code] && !w.deadLetter(ctx, msg, jobErr, attempt) {
+			w.nack(ctx, msg)
+			return
+		}
+	} else {
+		zlog.Logger.Info().Stringer("image", imageID).Int("attempt", attempt).Msg("image is processed")
+		metrics.Jobs.WithLabelValues(jobResultProcessed).Inc()
+		w.lastSuccess.Store(time.Now().UnixNano())
+	}
+
+	w.ack(ctx, msg)
+}
+
+// shareResult completes the image with the result of an identical image of
+// the same owner processed before and reports whether it did, the job needs
+// no work then. Its own original is removed unless other images share it.
+func (w *Worker) shareResult(ctx context.Context, imageID uuid.UUID) bool {
+	err := w.repo.ShareResult(ctx, imageID)
+	if err != nil {
+		if !errors.Is(err, images.ErrImageNotFound) {
+			zlog.Logger.Warn().Err(err).Stringer("image", imageID).Msg("worker.go - failed to share result")
+		}
+		return false
+	}
+
+	w.removeOriginal(ctx, imageID)
+
+	return true
+}
+
+// removeOriginal removes the original stored under imageID unless an image
+// still shares it.
+func (w *Worker) removeOriginal(ctx context.Context, imageID uuid.UUID) {
+	w.removeUnused(ctx, imageID, storage.OriginalKey(imageID))
+}
+
+// removeUnused removes key, an object stored under objectID, unless an image
+// still shares the objects of objectID.
+func (w *Worker) removeUnused(ctx context.Context, objectID uuid.UUID, key string) {
+	inUse, err := w.repo.ObjectInUse(ctx, objectID)
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Stringer("object", objectID).Msg("worker.go - failed to check whether the object is shared")
+		return
+	}
+	if inUse {
+		return
+	}
+	if err := w.store.Delete(ctx, key); err != nil {
+		zlog.Logger.Warn().Err(err).Str("key", key).Msg("worker.go - failed to remove object from storage")
+	}
+}
+
+// deadLetter publishes msg to the dead-letter queue and reports whether it
+// succeeded.
+func (w *Worker) deadLetter(ctx context.Context, msg queue.Message, jobErr *jobError, attempt int) bool {
+	err := w.dlq.Publish(ctx, queue.DeadLetterMessage(msg, queue.DeadLetter{
+		Reason:   jobErr.code,
+		Err:      jobErr.err,
+		Attempts: attempt,
+	}))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Int("partition", msg.Partition).Int64("offset", msg.Offset).Msg("worker.go - failed to publish dead letter")
+		return false
+	}
+	zlog.Logger.Warn().Str("reason", jobErr.code).Int("partition", msg.Partition).Int64("offset", msg.Offset).Msg("worker.go - message moved to dead-letter queue")
+	metrics.Jobs.WithLabelValues(jobResultDeadLettered).Inc()
+
+	return true
+}
+
+// ack commits the message, also while shutting down.
+func (w *Worker) ack(ctx context.Context, msg queue.Message) {
+	if err := w.queue.Ack(context.WithoutCancel(ctx), msg); err != nil {
+		zlog.Logger.Warn().Err(err).Int("partition", msg.Partition).Int64("offset", msg.Offset).Msg("worker.go - failed to ack message")
+	}
+}
+
+// nack hands the message back to the queue, also while shutting down.
+func (w *Worker) nack(ctx context.Context, msg queue.Message) {
+	metrics.Jobs.WithLabelValues(jobResultReturned).Inc()
+	if err := w.queue.Nack(context.WithoutCancel(ctx), msg); err != nil {
+		zlog.Logger.Warn().Err(err).Int("partition", msg.Partition).Int64("offset", msg.Offset).Msg("worker.go - failed to nack message")
+	}
+}
+
+// runJob processes a job until it succeeds, fails permanently or the image
+// used up worker.max_attempts. Attempts are counted on the image record, so
+// the budget also covers deliveries before a restart. Transient failures are
+// retried after retry.delay, growing by retry.backoff each time.
+func (w *Worker) runJob(ctx context.Context, imageID uuid.UUID, value []byte) (int, error) {
+	delay := w.retry.Delay
+	for try := 1; ; try++ {
+		attempt, err := w.processImage(ctx, imageID, value)
+		var jobErr *jobError
+		if err == nil || !errors.As(err, &jobErr) || !jobErr.transient || max(attempt, try) >= w.maxAttempts {
+			return attempt, err
+		}
+
+		zlog.Logger.Warn().Err(err).Stringer("image", imageID).Int("attempt", attempt).Dur("delay", delay).Msg("worker.go - retrying image")
+		metrics.Jobs.WithLabelValues(jobResultRetried).Inc()
+		if err := w.repo.RecordError(ctx, imageID, err.Error()); err != nil {
+			zlog.Logger.Warn().Err(err).Stringer("image", imageID).Msg("worker.go - failed to record error")
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = time.Duration(float64(delay) * w.retry.Backoff)
+	}
+}
+
+// processImage returns the attempt number of the job together with its error.
+// The objects are read and written under the object id of the image, which
+// differs from imageID once it shares the result of an identical image.
+func (w *Worker) processImage(ctx context.Context, imageID uuid.UUID, value []byte) (int, error) {
+	im, err := w.repo.CheckImage(ctx, imageID)
+	if err != nil && !errors.Is(err, images.ErrImageInProcess) && !errors.Is(err, images.ErrImageFailed) {
+		if errors.Is(err, images.ErrImageNotFound) {
+			w.removeOriginal(ctx, imageID)
+			return 0, errImageGone
+		}
+
+		return 0, &jobError{code: errCodeDatabaseFailed, err: fmt.Errorf("failed to check image - %w", err), transient: true}
+	}
+
+	attempt, err := w.repo.StartAttempt(ctx, imageID)
+	if err != nil {
+		return 0, &jobError{code: errCodeDatabaseFailed, err: fmt.Errorf("failed to start attempt - %w", err), transient: true}
+	}
+	zlog.Logger.Debug().Stringer("image", imageID).Int("attempt", attempt).Msg("worker.go - processing image")
+
+	imProc := models.ImageKafka{}
+	err = json.Unmarshal(value, &imProc)
+	if err != nil {
+		return attempt, &jobError{code: errCodeInvalidMessage, err: fmt.Errorf("failed to unmarshal message into struct - %w", err)}
+	}
+	if imProc.Variant == "" {
+		imProc.Variant = defaultVariant
+	}
+
+	object, _, err := w.store.Get(ctx, storage.OriginalKey(im.ObjectID))
+	if err != nil {
+		// A missing original will not appear by retrying.
+		transient := !errors.Is(err, storage.ErrNotFound)
+		return attempt, &jobError{code: errCodeStorageFailed, err: fmt.Errorf("failed to get image from storage - %w", err), transient: transient}
+	}
+	defer object.Close()
+	imageBytes, err := io.ReadAll(object)
+	if err != nil {
+		return attempt, &jobError{code: errCodeStorageFailed, err: fmt.Errorf("failed to read image from storage - %w", err), transient: true}
+	}
+
+	processedImage, err := w.handIm.ProcessImage(imageBytes, imProc.Pipeline)
+	if err != nil {
+		code := errCodeProcessingFailed
+		if errors.Is(err, handlers.ErrDecodeImage) {
+			code = errCodeDecodeFailed
+		} else if errors.Is(err, handlers.ErrEncodeImage) {
+			code = errCodeEncodeFailed
+		}
+		return attempt, &jobError{code: code, err: err}
+	}
+
+	objectName := storage.VariantKey(im.ObjectID, imProc.Variant)
+	imageAsReader := bytes.NewReader(processedImage.Data)
+	size := processedImage.Result.Size
+	err = w.store.Put(ctx, objectName, imageAsReader, size, processedImage.ContentType)
+	if err != nil {
+		return attempt, &jobError{code: errCodeStorageFailed, err: fmt.Errorf("failed to put processed image into storage - %w", err), transient: true}
+	}
+
+	err = w.repo.CompleteImage(ctx, imageID, processedImage.Original, processedImage.Result)
+	if errors.Is(err, images.ErrImageNotFound) {
+		// Deleted while it was processed, the variant has no image left.
+		w.removeUnused(ctx, im.ObjectID, objectName)
+		return attempt, errImageGone
+	}
+	if err != nil {
+		return attempt, &jobError{code: errCodeDatabaseFailed, err: fmt.Errorf("failed to change image status - %w", err), transient: true}
+	}
+
+	return attempt, nil
+}
+
+// failImage moves the image into the terminal failed state so clients stop
+// polling and reports whether that state was stored. Errors without a code
+// only get logged and the job is left to be delivered again.
+func (w *Worker) failImage(ctx context.Context, imageID uuid.UUID, err error) bool {
+	zlog.Logger.Warn().Err(err).Stringer("image", imageID).Msg("worker.go - failed to process image")
+
+	var jobErr *jobError
+	if !errors.As(err, &jobErr) {
+		return false
+	}
+	if err := w.repo.FailImage(ctx, imageID, jobErr.code, jobErr.err.Error()); err != nil {
+		zlog.Logger.Warn().Err(err).Stringer("image", imageID).Msg("worker.go - failed to mark image as failed")
+		return false
+	}
+
+	return true
 }
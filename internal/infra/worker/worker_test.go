@@ -0,0 +1,657 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	handlers "github.com/avraam311/image-processor/internal/infra/handlers/images"
+	"github.com/avraam311/image-processor/internal/infra/queue"
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+	"github.com/avraam311/image-processor/internal/repository/images"
+
+	"github.com/wb-go/wbf/config"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowHandler takes delay to process an image and reports the start.
+type slowHandler struct {
+	delay   time.Duration
+	started chan struct{}
+}
+
+func (h *slowHandler) ProcessImage(im []byte, pipeline []models.Operation) (*models.ProcessedImage, error) {
+	close(h.started)
+	time.Sleep(h.delay)
+	return &models.ProcessedImage{
+		Data:        []byte("x"),
+		ContentType: "image/png",
+		Result:      models.ImageInfo{Size: 1},
+	}, nil
+}
+
+// fakeRepository fails like a database call once ctx is done.
+type fakeRepository struct {
+	mu          sync.Mutex
+	completed   bool
+	failed      bool
+	failCode    string
+	failMessage string
+	// duplicate makes ShareResult find an identical processed image.
+	duplicate bool
+	inUse     bool
+	// missing makes CheckImage report a deleted image.
+	missing bool
+	// attempt is the attempt number StartAttempt returned last, set it to
+	// count deliveries before the test.
+	attempt int
+	// completeErrs are returned by the next calls of CompleteImage.
+	completeErrs []error
+	recorded     []string
+}
+
+func (r *fakeRepository) CompleteImage(ctx context.Context, id uuid.UUID, original, result models.ImageInfo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.completeErrs) > 0 {
+		err := r.completeErrs[0]
+		r.completeErrs = r.completeErrs[1:]
+		return err
+	}
+	r.completed = true
+	return nil
+}
+
+func (r *fakeRepository) CheckImage(ctx context.Context, id uuid.UUID) (*models.ImageRecord, error) {
+	if r.missing {
+		return nil, images.ErrImageNotFound
+	}
+	return &models.ImageRecord{ID: id, ObjectID: id}, nil
+}
+
+func (r *fakeRepository) FailImage(ctx context.Context, id uuid.UUID, code, message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = true
+	r.failCode = code
+	r.failMessage = message
+	return nil
+}
+
+func (r *fakeRepository) StartAttempt(ctx context.Context, id uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempt++
+	return r.attempt, nil
+}
+
+func (r *fakeRepository) RecordError(ctx context.Context, id uuid.UUID, message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded = append(r.recorded, message)
+	return nil
+}
+
+func (r *fakeRepository) ShareResult(ctx context.Context, id uuid.UUID) error {
+	if !r.duplicate {
+		return images.ErrImageNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.completed = true
+	return nil
+}
+
+func (r *fakeRepository) ObjectInUse(ctx context.Context, objectID uuid.UUID) (bool, error) {
+	return r.inUse, nil
+}
+
+// countingHandler counts the images it processes.
+type countingHandler struct {
+	calls int
+}
+
+func (h *countingHandler) ProcessImage(im []byte, pipeline []models.Operation) (*models.ProcessedImage, error) {
+	h.calls++
+	return &models.ProcessedImage{
+		Data:        []byte("x"),
+		ContentType: "image/png",
+		Result:      models.ImageInfo{Size: 1},
+	}, nil
+}
+
+// failingHandler returns its errors one call after the other and succeeds
+// once they are used up.
+type failingHandler struct {
+	errs  []error
+	calls int
+}
+
+func (h *failingHandler) ProcessImage(im []byte, pipeline []models.Operation) (*models.ProcessedImage, error) {
+	h.calls++
+	if h.calls <= len(h.errs) {
+		return nil, h.errs[h.calls-1]
+	}
+	return &models.ProcessedImage{
+		Data:        []byte("x"),
+		ContentType: "image/png",
+		Result:      models.ImageInfo{Size: 1},
+	}, nil
+}
+
+// failingStore returns putErrs from the next calls of Put.
+type failingStore struct {
+	storage.ObjectStore
+	putErrs []error
+}
+
+func (s *failingStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if len(s.putErrs) > 0 {
+		err := s.putErrs[0]
+		s.putErrs = s.putErrs[1:]
+		return err
+	}
+	return s.ObjectStore.Put(ctx, key, r, size, contentType)
+}
+
+// recordingQueue counts acks and nacks and fails Publish with publishErr.
+type recordingQueue struct {
+	*queue.Memory
+	publishErr error
+	acked      int
+	nacked     int
+}
+
+func (q *recordingQueue) Publish(ctx context.Context, msgs ...queue.Message) error {
+	if q.publishErr != nil {
+		return q.publishErr
+	}
+	return q.Memory.Publish(ctx, msgs...)
+}
+
+func (q *recordingQueue) Ack(ctx context.Context, msg queue.Message) error {
+	q.acked++
+	return q.Memory.Ack(ctx, msg)
+}
+
+func (q *recordingQueue) Nack(ctx context.Context, msg queue.Message) error {
+	q.nacked++
+	return nil
+}
+
+func TestHandleMessageDeadLetter(t *testing.T) {
+	decodeErr := fmt.Errorf("%w: broken", handlers.ErrDecodeImage)
+
+	tests := []struct {
+		name             string
+		key              string
+		handlerErr       error
+		publishErr       error
+		expectedReason   string
+		expectedAttempts string
+		expectedFailed   bool
+		expectedAcked    bool
+	}{
+		{
+			name:             "decode failure is dead-lettered",
+			handlerErr:       decodeErr,
+			expectedReason:   errCodeDecodeFailed,
+			expectedAttempts: "1",
+			expectedFailed:   true,
+			expectedAcked:    true,
+		},
+		{
+			name:             "invalid key is dead-lettered",
+			key:              "not-an-id",
+			expectedReason:   errCodeInvalidKey,
+			expectedAttempts: "0",
+			expectedAcked:    true,
+		},
+		{
+			name:           "processing failure is not dead-lettered",
+			handlerErr:     errors.New("step 1: unknown operation"),
+			expectedFailed: true,
+			expectedAcked:  true,
+		},
+		{
+			name:           "failed publish nacks",
+			handlerErr:     decodeErr,
+			publishErr:     errors.New("kafka down"),
+			expectedFailed: true,
+		},
+		{
+			name:       "failed publish of an invalid key nacks",
+			key:        "not-an-id",
+			publishErr: errors.New("kafka down"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.SetDefault("worker.max_attempts", 3)
+			jobs := &recordingQueue{Memory: queue.NewMemory("images", 10)}
+			dlq := &recordingQueue{Memory: queue.NewMemory("images-dlq", 10), publishErr: tt.publishErr}
+			store := storage.NewMemory()
+			handler := &failingHandler{}
+			if tt.handlerErr != nil {
+				handler.errs = []error{tt.handlerErr}
+			}
+			repo := &fakeRepository{}
+			w := New(jobs, dlq, cfg, store, handler, repo)
+
+			ctx := context.Background()
+			id := uuid.Must(uuid.NewV7())
+			require.NoError(t, store.Put(ctx, storage.OriginalKey(id), strings.NewReader("original"), 8, "image/png"))
+			key := tt.key
+			if key == "" {
+				key = id.String()
+			}
+			msg := queue.Message{
+				Key:     []byte(key),
+				Value:   []byte(`{"pipeline":[{"op":"grayscale"}],"variant":"processed"}`),
+				Headers: map[string]string{"trace": "abc"},
+				Queue:   "images",
+				Offset:  7,
+			}
+
+			w.handleMessage(ctx, msg)
+
+			assert.Equal(t, tt.expectedFailed, repo.failed)
+			if tt.expectedAcked {
+				assert.Equal(t, 1, jobs.acked)
+				assert.Equal(t, 0, jobs.nacked)
+			} else {
+				assert.Equal(t, 0, jobs.acked)
+				assert.Equal(t, 1, jobs.nacked)
+			}
+			if tt.expectedReason == "" {
+				assert.Equal(t, 0, dlq.Len())
+				return
+			}
+
+			require.Equal(t, 1, dlq.Len())
+			consumeCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			messages, err := dlq.Consume(consumeCtx)
+			require.NoError(t, err)
+			dead := <-messages
+			assert.Equal(t, msg.Key, dead.Key)
+			assert.Equal(t, msg.Value, dead.Value)
+			assert.Equal(t, "abc", dead.Headers["trace"])
+			assert.Equal(t, tt.expectedReason, dead.Headers[queue.HeaderReason])
+			assert.Equal(t, tt.expectedAttempts, dead.Headers[queue.HeaderAttempts])
+			assert.Equal(t, "images", dead.Headers[queue.HeaderOriginalTopic])
+			assert.Equal(t, "7", dead.Headers[queue.HeaderOriginalOffset])
+			assert.NotEmpty(t, dead.Headers[queue.HeaderError])
+		})
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	dbDown := errors.New("db down")
+	storageDown := errors.New("storage down")
+
+	tests := []struct {
+		name            string
+		priorAttempts   int
+		noOriginal      bool
+		handlerErrs     []error
+		putErrs         []error
+		completeErrs    []error
+		expectedAttempt int
+		expectedCalls   int
+		expectedRecords int
+		expectedCode    string
+	}{
+		{name: "success", expectedAttempt: 1, expectedCalls: 1},
+		{
+			name:            "transient store error is retried",
+			putErrs:         []error{storageDown},
+			expectedAttempt: 2, expectedCalls: 2, expectedRecords: 1,
+		},
+		{
+			name:            "transient repository error is retried",
+			completeErrs:    []error{dbDown, dbDown},
+			expectedAttempt: 3, expectedCalls: 3, expectedRecords: 2,
+		},
+		{
+			name:            "transient errors stop at max attempts",
+			putErrs:         []error{storageDown, storageDown, storageDown, storageDown},
+			expectedAttempt: 3, expectedCalls: 3, expectedRecords: 2,
+			expectedCode: errCodeStorageFailed,
+		},
+		{
+			name:            "attempts of earlier deliveries count",
+			priorAttempts:   2,
+			completeErrs:    []error{dbDown},
+			expectedAttempt: 3, expectedCalls: 1,
+			expectedCode: errCodeDatabaseFailed,
+		},
+		{
+			name:            "decode error is permanent",
+			handlerErrs:     []error{fmt.Errorf("%w: broken", handlers.ErrDecodeImage)},
+			expectedAttempt: 1, expectedCalls: 1,
+			expectedCode: errCodeDecodeFailed,
+		},
+		{
+			name:            "processing error is permanent",
+			handlerErrs:     []error{errors.New("step 1: unknown operation")},
+			expectedAttempt: 1, expectedCalls: 1,
+			expectedCode: errCodeProcessingFailed,
+		},
+		{
+			name:            "missing original is permanent",
+			noOriginal:      true,
+			expectedAttempt: 1,
+			expectedCode:    errCodeStorageFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.SetDefault("worker.max_attempts", 3)
+			cfg.SetDefault("retry.delay", time.Millisecond)
+			cfg.SetDefault("retry.backoff", 1.0)
+			store := &failingStore{ObjectStore: storage.NewMemory(), putErrs: tt.putErrs}
+			handler := &failingHandler{errs: tt.handlerErrs}
+			repo := &fakeRepository{attempt: tt.priorAttempts, completeErrs: tt.completeErrs}
+			w := New(queue.NewMemory("images", 10), queue.NewMemory("images-dlq", 10), cfg, store, handler, repo)
+
+			ctx := context.Background()
+			id := uuid.Must(uuid.NewV7())
+			if !tt.noOriginal {
+				require.NoError(t, store.ObjectStore.Put(ctx, storage.OriginalKey(id), strings.NewReader("original"), 8, "image/png"))
+			}
+
+			attempt, err := w.runJob(ctx, id, []byte(`{"pipeline":[{"op":"grayscale"}],"variant":"processed"}`))
+
+			assert.Equal(t, tt.expectedAttempt, attempt)
+			assert.Equal(t, tt.expectedCalls, handler.calls)
+			assert.Len(t, repo.recorded, tt.expectedRecords)
+			if tt.expectedCode == "" {
+				require.NoError(t, err)
+				assert.True(t, repo.completed)
+				return
+			}
+			require.Error(t, err)
+			assert.False(t, repo.completed)
+			require.True(t, w.failImage(ctx, id, err))
+			assert.True(t, repo.failed)
+			assert.Equal(t, tt.expectedCode, repo.failCode)
+			assert.NotEmpty(t, repo.failMessage)
+		})
+	}
+}
+
+func TestHandleMessageFailsImage(t *testing.T) {
+	tests := []struct {
+		name            string
+		handlerErr      error
+		expectedCode    string
+		expectedMessage string
+	}{
+		{
+			name:            "processing error",
+			handlerErr:      errors.New("step 2: crop rectangle is outside of the image"),
+			expectedCode:    errCodeProcessingFailed,
+			expectedMessage: "step 2: crop rectangle is outside of the image",
+		},
+		{
+			name:            "decode error",
+			handlerErr:      fmt.Errorf("%w: unexpected EOF", handlers.ErrDecodeImage),
+			expectedCode:    errCodeDecodeFailed,
+			expectedMessage: "failed to decode image: unexpected EOF",
+		},
+		{
+			name:            "encode error",
+			handlerErr:      fmt.Errorf("%w: unsupported format", handlers.ErrEncodeImage),
+			expectedCode:    errCodeEncodeFailed,
+			expectedMessage: "failed to encode image: unsupported format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.SetDefault("worker.max_attempts", 3)
+			jobs := &recordingQueue{Memory: queue.NewMemory("images", 10)}
+			store := storage.NewMemory()
+			repo := &fakeRepository{}
+			w := New(jobs, queue.NewMemory("images-dlq", 10), cfg, store, &failingHandler{errs: []error{tt.handlerErr}}, repo)
+
+			ctx := context.Background()
+			id := uuid.Must(uuid.NewV7())
+			require.NoError(t, store.Put(ctx, storage.OriginalKey(id), strings.NewReader("original"), 8, "image/png"))
+
+			w.handleMessage(ctx, queue.Message{
+				Key:   []byte(id.String()),
+				Value: []byte(`{"pipeline":[{"op":"grayscale"}],"variant":"processed"}`),
+			})
+
+			assert.True(t, repo.failed)
+			assert.False(t, repo.completed)
+			assert.Equal(t, tt.expectedCode, repo.failCode)
+			assert.Equal(t, tt.expectedMessage, repo.failMessage)
+			assert.Equal(t, 1, jobs.acked)
+			assert.Nil(t, w.Status().LastSuccessAt)
+		})
+	}
+}
+
+func TestFailImageWithoutCode(t *testing.T) {
+	repo := &fakeRepository{}
+	w := New(queue.NewMemory("images", 10), queue.NewMemory("images-dlq", 10), config.New(), storage.NewMemory(), &countingHandler{}, repo)
+
+	assert.False(t, w.failImage(context.Background(), uuid.Must(uuid.NewV7()), errors.New("context canceled")))
+	assert.False(t, repo.failed)
+}
+
+func TestHandleMessageImageGone(t *testing.T) {
+	cfg := config.New()
+	cfg.SetDefault("worker.max_attempts", 3)
+	jobs := &recordingQueue{Memory: queue.NewMemory("images", 10)}
+	store := storage.NewMemory()
+	handler := &countingHandler{}
+	repo := &fakeRepository{missing: true}
+	w := New(jobs, queue.NewMemory("images-dlq", 10), cfg, store, handler, repo)
+
+	ctx := context.Background()
+	id := uuid.Must(uuid.NewV7())
+	require.NoError(t, store.Put(ctx, storage.OriginalKey(id), strings.NewReader("original"), 8, "image/png"))
+	processed := metrics.Jobs.WithLabelValues(jobResultProcessed)
+	discarded := metrics.Jobs.WithLabelValues(jobResultDiscarded)
+	processedBefore, discardedBefore := testutil.ToFloat64(processed), testutil.ToFloat64(discarded)
+
+	w.handleMessage(ctx, queue.Message{
+		Key:   []byte(id.String()),
+		Value: []byte(`{"pipeline":[{"op":"grayscale"}],"variant":"processed"}`),
+	})
+
+	assert.Equal(t, 1, jobs.acked)
+	assert.Equal(t, 0, jobs.nacked)
+	assert.Equal(t, 0, handler.calls)
+	assert.False(t, repo.completed)
+	assert.False(t, repo.failed)
+	assert.Nil(t, w.Status().LastSuccessAt)
+	assert.Equal(t, processedBefore, testutil.ToFloat64(processed))
+	assert.Equal(t, discardedBefore+1, testutil.ToFloat64(discarded))
+	_, err := store.Stat(ctx, storage.OriginalKey(id))
+	assert.True(t, errors.Is(err, storage.ErrNotFound))
+}
+
+func TestHandleMessageImageDeletedWhileProcessing(t *testing.T) {
+	tests := []struct {
+		name            string
+		inUse           bool
+		expectedVariant bool
+	}{
+		{name: "unshared object", expectedVariant: false},
+		{name: "shared object", inUse: true, expectedVariant: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.SetDefault("worker.max_attempts", 3)
+			jobs := &recordingQueue{Memory: queue.NewMemory("images", 10)}
+			store := storage.NewMemory()
+			handler := &countingHandler{}
+			repo := &fakeRepository{inUse: tt.inUse, completeErrs: []error{images.ErrImageNotFound}}
+			w := New(jobs, queue.NewMemory("images-dlq", 10), cfg, store, handler, repo)
+
+			ctx := context.Background()
+			id := uuid.Must(uuid.NewV7())
+			require.NoError(t, store.Put(ctx, storage.OriginalKey(id), strings.NewReader("original"), 8, "image/png"))
+			discarded := metrics.Jobs.WithLabelValues(jobResultDiscarded)
+			discardedBefore := testutil.ToFloat64(discarded)
+
+			w.handleMessage(ctx, queue.Message{
+				Key:   []byte(id.String()),
+				Value: []byte(`{"pipeline":[{"op":"grayscale"}],"variant":"small"}`),
+			})
+
+			assert.Equal(t, 1, jobs.acked)
+			assert.Equal(t, 0, jobs.nacked)
+			assert.Equal(t, 1, handler.calls)
+			assert.Equal(t, 1, repo.attempt)
+			assert.Empty(t, repo.recorded)
+			assert.False(t, repo.completed)
+			assert.False(t, repo.failed)
+			assert.Equal(t, discardedBefore+1, testutil.ToFloat64(discarded))
+			_, err := store.Stat(ctx, storage.VariantKey(id, "small"))
+			if tt.expectedVariant {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, storage.ErrNotFound))
+			}
+		})
+	}
+}
+
+func TestHandleMessageDuplicate(t *testing.T) {
+	tests := []struct {
+		name             string
+		duplicate        bool
+		inUse            bool
+		expectedCalls    int
+		expectedOriginal bool
+	}{
+		{name: "unique image", expectedCalls: 1, expectedOriginal: true},
+		{name: "duplicate", duplicate: true},
+		{name: "duplicate with shared original", duplicate: true, inUse: true, expectedOriginal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.SetDefault("worker.max_attempts", 5)
+			jobs := queue.NewMemory("images", 10)
+			store := storage.NewMemory()
+			handler := &countingHandler{}
+			repo := &fakeRepository{duplicate: tt.duplicate, inUse: tt.inUse}
+			w := New(jobs, queue.NewMemory("images-dlq", 10), cfg, store, handler, repo)
+
+			ctx := context.Background()
+			id := uuid.Must(uuid.NewV7())
+			require.NoError(t, store.Put(ctx, storage.OriginalKey(id), strings.NewReader("original"), 8, "image/png"))
+
+			w.handleMessage(ctx, queue.Message{
+				Key:   []byte(id.String()),
+				Value: []byte(`{"pipeline":[{"op":"grayscale"}],"variant":"processed"}`),
+			})
+
+			assert.True(t, repo.completed)
+			assert.False(t, repo.failed)
+			assert.Equal(t, tt.expectedCalls, handler.calls)
+			_, err := store.Stat(ctx, storage.OriginalKey(id))
+			assert.Equal(t, tt.expectedOriginal, err == nil)
+			assert.NotNil(t, w.Status().LastSuccessAt)
+		})
+	}
+}
+
+func TestRunDrainsOnShutdown(t *testing.T) {
+	tests := []struct {
+		name              string
+		delay             time.Duration
+		shutdownTimeout   time.Duration
+		expectedCompleted bool
+		expectedRequeued  bool
+	}{
+		{name: "job finishes within the timeout", delay: 50 * time.Millisecond, shutdownTimeout: time.Second, expectedCompleted: true},
+		{name: "job returned after the timeout", delay: 200 * time.Millisecond, shutdownTimeout: 20 * time.Millisecond, expectedRequeued: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.SetDefault("worker.count", 2)
+			cfg.SetDefault("worker.max_attempts", 5)
+			cfg.SetDefault("worker.shutdown_timeout", tt.shutdownTimeout)
+			cfg.SetDefault("retry.delay", time.Millisecond)
+			cfg.SetDefault("retry.backoff", 1.0)
+
+			jobs := queue.NewMemory("images", 10)
+			dlq := queue.NewMemory("images-dlq", 10)
+			store := storage.NewMemory()
+			handler := &slowHandler{delay: tt.delay, started: make(chan struct{})}
+			repo := &fakeRepository{}
+			w := New(jobs, dlq, cfg, store, handler, repo)
+
+			ctx := context.Background()
+			id := uuid.Must(uuid.NewV7())
+			require.NoError(t, store.Put(ctx, storage.OriginalKey(id), strings.NewReader("original"), 8, "image/png"))
+			require.NoError(t, jobs.Publish(ctx, queue.Message{
+				Key:   []byte(id.String()),
+				Value: []byte(`{"pipeline":[{"op":"grayscale"}],"variant":"processed"}`),
+			}))
+
+			runCtx, cancel := context.WithCancel(ctx)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				w.Run(runCtx)
+			}()
+			<-handler.started
+			assert.True(t, w.Status().Consuming)
+			assert.NoError(t, w.Ready(ctx))
+			cancel()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run did not return")
+			}
+
+			assert.Equal(t, tt.expectedCompleted, repo.completed)
+			status := w.Status()
+			assert.False(t, status.Consuming)
+			assert.Equal(t, tt.expectedCompleted, status.LastSuccessAt != nil)
+			assert.Error(t, w.Ready(ctx))
+			assert.False(t, repo.failed)
+			_, err := store.Stat(ctx, storage.VariantKey(id, "processed"))
+			assert.NoError(t, err)
+			if tt.expectedRequeued {
+				assert.Eventually(t, func() bool { return jobs.Len() == 1 }, time.Second, 10*time.Millisecond)
+			} else {
+				assert.Equal(t, 0, jobs.Len())
+			}
+		})
+	}
+}
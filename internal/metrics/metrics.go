@@ -0,0 +1,119 @@
+// Package metrics defines the Prometheus collectors of the API and the
+// worker. They are registered with the default registry, Handler serves it.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "image_processor"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	UploadSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upload_size_bytes",
+		Help:      "Size of uploaded originals.",
+		Buckets:   prometheus.ExponentialBuckets(16<<10, 4, 8),
+	})
+
+	DeduplicatedUploads = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "uploads_deduplicated_total",
+		Help:      "Uploads sharing the result of an identical image processed before.",
+	})
+
+	Jobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_total",
+		Help:      "Jobs handled by the worker by result: processed, deduplicated, failed, dead_lettered, retried, returned to the queue or discarded because the image is gone.",
+	}, []string{"result"})
+
+	JobDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Time from fetching a job to acknowledging it, retries included.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+
+	JobsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "jobs_in_flight",
+		Help:      "Jobs being processed right now.",
+	})
+
+	QueueLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "queue_lag_seconds",
+		Help:      "Time a job spent in the queue before a worker fetched it.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	})
+
+	Operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operations_total",
+		Help:      "Pipeline steps by operation and result: ok or failed. Decoding counts as the decode operation.",
+	}, []string{"op", "result"})
+
+	OperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of pipeline steps by operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"op"})
+
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_duration_seconds",
+		Help:      "Object storage call latency by method and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "result"})
+
+	DBDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_duration_seconds",
+		Help:      "Repository call latency by query.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query"})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Result labels err as ok or failed.
+func Result(err error) string {
+	if err != nil {
+		return "failed"
+	}
+
+	return "ok"
+}
+
+// ObserveDB records the latency of a repository call, it is meant to be
+// deferred first thing in the call:
+//
+//	defer metrics.ObserveDB("check_image", time.Now())
+func ObserveDB(query string, start time.Time) {
+	DBDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/avraam311/image-processor/internal/api/handlers"
+	"github.com/avraam311/image-processor/internal/auth"
+	"github.com/avraam311/image-processor/internal/models"
+	"github.com/avraam311/image-processor/internal/repository/keys"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	bearerPrefix = "Bearer "
+)
+
+type KeyFinder interface {
+	FindKey(context.Context, string) (*models.APIKey, error)
+}
+
+// AuthMiddleware accepts requests carrying an active API key, either as
+// "Authorization: Bearer <key>" or in the X-API-Key header, and stores the
+// key in the request context as the owner of what the request creates.
+func AuthMiddleware(finder KeyFinder) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		key := c.GetHeader(apiKeyHeader)
+		if authorization := c.GetHeader("Authorization"); key == "" && strings.HasPrefix(authorization, bearerPrefix) {
+			key = strings.TrimPrefix(authorization, bearerPrefix)
+		}
+		if key == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			handlers.Fail(c.Writer, http.StatusUnauthorized, fmt.Errorf("api key required"))
+			c.Abort()
+			return
+		}
+
+		apiKey, err := finder.FindKey(c.Request.Context(), auth.HashKey(key))
+		if err != nil {
+			if errors.Is(err, keys.ErrKeyNotFound) {
+				c.Header("WWW-Authenticate", "Bearer")
+				handlers.Fail(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid api key"))
+				c.Abort()
+				return
+			}
+
+			zlog.Logger.Error().Err(err).Msg("failed to find api key")
+			handlers.Fail(c.Writer, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(auth.WithOwner(c.Request.Context(), apiKey.ID))
+		c.Next()
+	}
+}
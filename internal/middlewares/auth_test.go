@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/avraam311/image-processor/internal/auth"
+	"github.com/avraam311/image-processor/internal/models"
+	"github.com/avraam311/image-processor/internal/repository/keys"
+
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeKeyFinder struct {
+	keys map[string]*models.APIKey
+	err  error
+}
+
+func (f *fakeKeyFinder) FindKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	key, ok := f.keys[hash]
+	if !ok {
+		return nil, keys.ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         string
+		value          string
+		finderErr      error
+		expectedStatus int
+		expectedOwner  string
+	}{
+		{name: "bearer", header: "Authorization", value: "Bearer ip_valid", expectedStatus: http.StatusOK, expectedOwner: "7"},
+		{name: "api key header", header: apiKeyHeader, value: "ip_valid", expectedStatus: http.StatusOK, expectedOwner: "7"},
+		{name: "missing", expectedStatus: http.StatusUnauthorized},
+		{name: "not bearer", header: "Authorization", value: "Basic ip_valid", expectedStatus: http.StatusUnauthorized},
+		{name: "unknown", header: apiKeyHeader, value: "ip_unknown", expectedStatus: http.StatusUnauthorized},
+		{name: "db error", header: apiKeyHeader, value: "ip_valid", finderErr: errors.New("db error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finder := &fakeKeyFinder{
+				keys: map[string]*models.APIKey{auth.HashKey("ip_valid"): {ID: 7}},
+				err:  tt.finderErr,
+			}
+			e := ginext.New("release")
+			e.Use(AuthMiddleware(finder))
+			e.GET("/images", func(c *ginext.Context) {
+				owner, _ := auth.Owner(c.Request.Context())
+				c.String(http.StatusOK, "%d", owner)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/images", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedOwner != "" {
+				assert.Equal(t, tt.expectedOwner, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
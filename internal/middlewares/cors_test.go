@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	e := ginext.New("release")
+	e.Use(CORSMiddleware())
+	e.POST("/upload", func(c *ginext.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		expectedStatus int
+	}{
+		{name: "preflight", method: http.MethodOptions, expectedStatus: http.StatusNoContent},
+		{name: "request", method: http.MethodPost, expectedStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(tt.method, "/upload", nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Idempotency-Key")
+			exposed := w.Header().Get("Access-Control-Expose-Headers")
+			for _, header := range []string{"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-Quota", "X-Quota-Limit", "X-Quota-Used", "Idempotent-Replayed"} {
+				assert.Contains(t, exposed, header)
+			}
+		})
+	}
+}
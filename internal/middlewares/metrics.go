@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/wb-go/wbf/ginext"
+)
+
+const (
+	unmatchedRoute = "unmatched"
+)
+
+// MetricsMiddleware counts requests and records their latency by route
+// template, so /image/1 and /image/2 share one series.
+func MetricsMiddleware() ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
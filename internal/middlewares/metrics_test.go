@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	e := ginext.New("release")
+	e.Use(MetricsMiddleware())
+	e.GET("/image/:id", func(c *ginext.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedRoute  string
+		expectedStatus string
+	}{
+		{name: "route template", path: "/image/1", expectedRoute: "/image/:id", expectedStatus: "200"},
+		{name: "same template", path: "/image/2", expectedRoute: "/image/:id", expectedStatus: "200"},
+		{name: "unmatched", path: "/nope", expectedRoute: unmatchedRoute, expectedStatus: "404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := metrics.HTTPRequests.WithLabelValues(http.MethodGet, tt.expectedRoute, tt.expectedStatus)
+			before := testutil.ToFloat64(counter)
+
+			e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, before+1, testutil.ToFloat64(counter))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/avraam311/image-processor/internal/api/handlers"
+	"github.com/avraam311/image-processor/internal/auth"
+	"github.com/avraam311/image-processor/internal/ratelimit"
+
+	"github.com/wb-go/wbf/ginext"
+)
+
+// RateLimitMiddleware limits route per client: the API key of the request,
+// or the client IP when there is none. It has to run after AuthMiddleware.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, route string) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		client := "ip:" + c.ClientIP()
+		if owner, ok := auth.Owner(c.Request.Context()); ok {
+			client = "key:" + strconv.FormatUint(uint64(owner), 10)
+		}
+
+		allow(c, limiter, route, client)
+	}
+}
+
+// IPRateLimitMiddleware limits route per client IP whatever the API key. It
+// runs before AuthMiddleware, so requests with missing or guessed keys are
+// limited before a key is looked up.
+func IPRateLimitMiddleware(limiter *ratelimit.Limiter, route string) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		allow(c, limiter, route, "ip:"+c.ClientIP())
+	}
+}
+
+// allow takes a token of client for route or aborts the request with 429.
+func allow(c *ginext.Context, limiter *ratelimit.Limiter, route, client string) {
+	res := limiter.Allow(route, client)
+	if res.Limit > 0 {
+		c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	}
+	if !res.Allowed {
+		handlers.TooManyRequests(c.Writer, res.RetryAfter, fmt.Errorf("rate limit exceeded"))
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/auth"
+	"github.com/avraam311/image-processor/internal/models"
+	"github.com/avraam311/image-processor/internal/ratelimit"
+
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limit{Rate: 0.001, Burst: 1}, nil, time.Hour)
+	e := ginext.New("release")
+	e.Use(func(c *ginext.Context) {
+		if key := c.GetHeader("X-Owner"); key == "1" {
+			c.Request = c.Request.WithContext(auth.WithOwner(c.Request.Context(), 1))
+		}
+	})
+	e.GET("/images", RateLimitMiddleware(limiter, "read"), func(c *ginext.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		owner          string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{name: "ip", remoteAddr: "10.0.0.1:1234", expectedStatus: http.StatusOK},
+		{name: "ip limited", remoteAddr: "10.0.0.1:1234", expectedStatus: http.StatusTooManyRequests},
+		{name: "other ip", remoteAddr: "10.0.0.2:1234", expectedStatus: http.StatusOK},
+		{name: "key", owner: "1", remoteAddr: "10.0.0.1:1234", expectedStatus: http.StatusOK},
+		{name: "key limited from another ip", owner: "1", remoteAddr: "10.0.0.3:1234", expectedStatus: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/images", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.owner != "" {
+				req.Header.Set("X-Owner", tt.owner)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+			assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+			if tt.expectedStatus == http.StatusTooManyRequests {
+				assert.Equal(t, "1000", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestIPRateLimitMiddleware(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limit{Rate: 0.001, Burst: 2}, nil, time.Hour)
+	finder := &fakeKeyFinder{keys: map[string]*models.APIKey{auth.HashKey("ip_valid"): {ID: 7}}}
+	e := ginext.New("release")
+	e.Use(IPRateLimitMiddleware(limiter, "ip"), AuthMiddleware(finder))
+	e.GET("/images", func(c *ginext.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		key            string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{name: "guessed key", key: "ip_guess1", remoteAddr: "10.0.0.1:1234", expectedStatus: http.StatusUnauthorized},
+		{name: "valid key", key: "ip_valid", remoteAddr: "10.0.0.1:1234", expectedStatus: http.StatusOK},
+		{name: "limited before the key is checked", key: "ip_guess2", remoteAddr: "10.0.0.1:1234", expectedStatus: http.StatusTooManyRequests},
+		{name: "valid key limited by ip", key: "ip_valid", remoteAddr: "10.0.0.1:1234", expectedStatus: http.StatusTooManyRequests},
+		{name: "other ip", key: "ip_valid", remoteAddr: "10.0.0.2:1234", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/images", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(apiKeyHeader, tt.key)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+		})
+	}
+}
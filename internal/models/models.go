@@ -1,10 +1,225 @@
 package models
 
+import (
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
 type Image struct {
-	Image      []byte `json:"image" validate:"required"`
-	Processing string `json:"processing" validate:"required"`
+	File        io.Reader         `json:"-"`
+	Size        int64             `json:"-"`
+	FileName    string            `json:"-"`
+	ContentType string            `json:"-"`
+	Processing  string            `json:"processing" validate:"omitempty,oneof=resize thumbnail watermark"`
+	Params      *ProcessingParams `json:"params" validate:"omitempty"`
+	Pipeline    []Operation       `json:"pipeline" validate:"omitempty,max=16,dive"`
+	Output      *OutputParams     `json:"output" validate:"omitempty"`
+	Variant     string            `json:"variant" validate:"omitempty,max=64,alphanum"`
+	// IdempotencyKey makes retries of the upload return the first result.
+	IdempotencyKey string `json:"-" validate:"omitempty,max=255"`
+	// ContentHash and SpecHash are set by the service, see ImageRecord.
+	ContentHash string `json:"-"`
+	SpecHash    string `json:"-"`
+}
+
+type ImageJSON struct {
+	Image      []byte            `json:"image" validate:"required"`
+	Processing string            `json:"processing"`
+	Params     *ProcessingParams `json:"params"`
+	Pipeline   []Operation       `json:"pipeline"`
+	Output     *OutputParams     `json:"output"`
+	Variant    string            `json:"variant" validate:"omitempty,max=64,alphanum"`
 }
 
 type ImageKafka struct {
-	Processing string `json:"processing" validate:"required"`
+	Pipeline []Operation `json:"pipeline" validate:"required,min=1"`
+	Variant  string      `json:"variant" validate:"required"`
+}
+
+// OutboxMessage is a job waiting in the outbox table to be published.
+type OutboxMessage struct {
+	ID      int64
+	ImageID uuid.UUID
+	Payload []byte
+}
+
+// ProcessingParams configures resize-like operations. A zero Width or Height
+// keeps the aspect ratio of the source; fill and pad need both of them.
+type ProcessingParams struct {
+	Width      int    `json:"width,omitempty" validate:"gte=0,lte=10000"`
+	Height     int    `json:"height,omitempty" validate:"gte=0,lte=10000"`
+	Fit        string `json:"fit,omitempty" validate:"omitempty,oneof=fill fit stretch pad"`
+	Filter     string `json:"filter,omitempty" validate:"omitempty,oneof=lanczos catmullrom linear nearest"`
+	Background string `json:"background,omitempty" validate:"omitempty,hexcolor"`
+}
+
+// EncodeParams selects the output format. Quality applies to JPEG,
+// Compression to PNG and Colors (the palette size) to GIF. An empty Format
+// keeps the source format when it can be encoded.
+type EncodeParams struct {
+	Format      string `json:"format,omitempty" validate:"omitempty,oneof=jpeg png gif bmp tiff"`
+	Quality     int    `json:"quality,omitempty" validate:"gte=0,lte=100"`
+	Compression string `json:"compression,omitempty" validate:"omitempty,oneof=default none fast best"`
+	Colors      int    `json:"colors,omitempty" validate:"gte=0,lte=256"`
+}
+
+// OutputParams are the output options of an upload, turned into a final
+// encode step: the encoder options and the color transparent pictures are
+// flattened onto when they are written as JPEG.
+type OutputParams struct {
+	EncodeParams
+	Background string `json:"background,omitempty" validate:"omitempty,hexcolor"`
+}
+
+// Operation is a single step of a processing pipeline. Only the fields
+// relevant to Op are used: X and Y are the crop origin, Sigma is the blur and
+// sharpen strength, EncodeParams configure the final encode step.
+type Operation struct {
+	Op string `json:"op" validate:"required,oneof=auto-orient crop resize thumbnail sharpen blur grayscale watermark encode"`
+	ProcessingParams
+	X     int     `json:"x,omitempty" validate:"gte=0"`
+	Y     int     `json:"y,omitempty" validate:"gte=0"`
+	Sigma float64 `json:"sigma,omitempty" validate:"gte=0,lte=100"`
+	EncodeParams
+}
+
+type ImageObject struct {
+	Data         []byte
+	ContentType  string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+// ProcessedImage is the encoded result of a pipeline together with the
+// dimensions of the decoded source and of the result.
+type ProcessedImage struct {
+	Data        []byte
+	ContentType string
+	Original    ImageInfo
+	Result      ImageInfo
+}
+
+type ImageInfo struct {
+	FileName    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Size        int64  `json:"size"`
+}
+
+type ImageRecord struct {
+	ID           uuid.UUID   `json:"id"`
+	Status       string      `json:"status"`
+	Variant      string      `json:"variant"`
+	SourceFormat string      `json:"source_format"`
+	Processing   []Operation `json:"processing"`
+	Original     ImageInfo   `json:"original"`
+	Result       *ImageInfo  `json:"result,omitempty"`
+	Attempts     int         `json:"attempts"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	ProcessedAt  *time.Time  `json:"processed_at,omitempty"`
+	LastError    string      `json:"last_error,omitempty"`
+	Error        *ImageError `json:"error,omitempty"`
+	// ContentHash is the hex SHA-256 of the original, empty for images
+	// uploaded before it was recorded. It isn't returned, so clients can't
+	// probe whether a known file was uploaded.
+	ContentHash string `json:"-"`
+	// OwnerID is the API key that uploaded the image, 0 for anonymous uploads.
+	OwnerID uint `json:"-"`
+	// SpecHash is the hex SHA-256 of the processing job of the image.
+	SpecHash string `json:"-"`
+	// ObjectID is the id the objects of the image are stored under. It is ID
+	// unless the image shares the objects of an identical one.
+	ObjectID uuid.UUID `json:"-"`
+}
+
+// UploadResult is the response to an upload. Replayed is set when it is the
+// stored response to an earlier upload with the same idempotency key.
+type UploadResult struct {
+	ID         uuid.UUID
+	StatusCode int
+	Replayed   bool
+}
+
+// IdempotencyRecord is the first upload made with an idempotency key,
+// ImageID and StatusCode stay unset until that upload completes.
+type IdempotencyRecord struct {
+	Fingerprint string
+	ImageID     uuid.UUID
+	StatusCode  int
+}
+
+// LegacyImage pairs the serial id an image was stored under before it had a
+// public id with that public id. Variant and Processed tell what the baseline
+// object under the bare serial id holds: the processed result overwrote the
+// original there.
+type LegacyImage struct {
+	ID        int64
+	PublicID  uuid.UUID
+	Variant   string
+	Processed bool
+}
+
+// ImageFilter selects images for a listing. Empty fields match everything,
+// Processing matches images whose pipeline contains that operation.
+type ImageFilter struct {
+	Status       string     `validate:"omitempty,oneof='in process' processed failed"`
+	Processing   string     `validate:"omitempty,oneof=auto-orient crop resize thumbnail sharpen blur grayscale watermark encode"`
+	SourceFormat string     `validate:"omitempty,oneof=jpeg png gif bmp tiff webp"`
+	CreatedFrom  *time.Time `validate:"omitempty"`
+	CreatedTo    *time.Time `validate:"omitempty"`
+	Sort         string     `validate:"oneof=asc desc"`
+	Limit        int        `validate:"gte=1,lte=100"`
+	Cursor       string
+	// OwnerID restricts the listing to the images of one API key when set.
+	OwnerID uint
+}
+
+// ImageCursor is the position of the last image of a page, images are
+// ordered by creation time and then by id.
+type ImageCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID `json:"id"`
+}
+
+type ImagePage struct {
+	Images     []*ImageRecord `json:"images"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// APIKey identifies a client. Only the hash of the key is stored, Prefix is
+// kept to tell keys apart in listings.
+type APIKey struct {
+	ID        uint        `json:"id"`
+	Name      string      `json:"name"`
+	Prefix    string      `json:"prefix"`
+	CreatedAt time.Time   `json:"created_at"`
+	RevokedAt *time.Time  `json:"revoked_at,omitempty"`
+	Quota     QuotaLimits `json:"quota"`
+}
+
+// QuotaLimits are the limits of one API key. Nil limits use the configured
+// defaults, 0 means unlimited.
+type QuotaLimits struct {
+	MaxImages     *int64 `json:"max_images,omitempty"`
+	MaxBytes      *int64 `json:"max_bytes,omitempty"`
+	MaxJobsPerDay *int64 `json:"max_jobs_per_day,omitempty"`
+}
+
+// Quota is what an API key may use and what it uses: the images it stores,
+// their bytes (originals and results) and the uploads of the current UTC day.
+type Quota struct {
+	QuotaLimits
+	Images    int64
+	Bytes     int64
+	JobsToday int64
+}
+
+type ImageError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
@@ -0,0 +1,126 @@
+// Package ratelimit keeps a token bucket per client and route, so one client
+// can't exhaust the API for the others.
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/config"
+
+	"golang.org/x/time/rate"
+)
+
+// Limit refills Rate tokens per second up to Burst, which is at least 1. A
+// zero Rate disables limiting.
+type Limit struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// Result is the outcome of Allow. Remaining is the number of requests left
+// right now, RetryAfter how long a rejected client has to wait.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+type Limiter struct {
+	def    Limit
+	routes map[string]Limit
+	idle   time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// New creates a Limiter applying routes[route] and def to other routes.
+// Buckets unused for idle are dropped; idle should be longer than a bucket
+// takes to refill, a dropped bucket starts full again.
+func New(def Limit, routes map[string]Limit, idle time.Duration) *Limiter {
+	return &Limiter{
+		def:     def,
+		routes:  routes,
+		idle:    idle,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// FromConfig creates the Limiter of the rate_limit section, or nil when rate
+// limiting is disabled.
+func FromConfig(cfg *config.Config) (*Limiter, error) {
+	if !cfg.GetBool("rate_limit.enabled") {
+		return nil, nil
+	}
+
+	routes := map[string]Limit{}
+	if err := cfg.UnmarshalKey("rate_limit.routes", &routes); err != nil {
+		return nil, fmt.Errorf("ratelimit.go - failed to read rate_limit.routes - %w", err)
+	}
+	def := Limit{
+		Rate:  cfg.GetFloat64("rate_limit.rate"),
+		Burst: cfg.GetInt("rate_limit.burst"),
+	}
+
+	return New(def, routes, cfg.GetDuration("rate_limit.idle_timeout")), nil
+}
+
+// Allow takes a token from the bucket of client on route.
+func (l *Limiter) Allow(route, client string) Result {
+	limit, ok := l.routes[route]
+	if !ok {
+		limit = l.def
+	}
+	if limit.Rate <= 0 {
+		return Result{Allowed: true}
+	}
+	burst := max(limit.Burst, 1)
+
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	key := route + "\x00" + client
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.Rate), burst)}
+		l.buckets[key] = b
+	}
+	b.seen = now
+
+	res := Result{Allowed: true, Limit: burst}
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		res.Allowed = false
+		res.RetryAfter = delay
+	}
+	res.Remaining = max(int(b.limiter.TokensAt(now)), 0)
+
+	return res
+}
+
+// sweep drops the buckets unused for idle, at most once per idle.
+func (l *Limiter) sweep(now time.Time) {
+	if l.idle <= 0 || now.Sub(l.swept) < l.idle {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.seen) >= l.idle {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
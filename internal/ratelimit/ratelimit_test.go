@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wb-go/wbf/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	l := New(Limit{Rate: 1, Burst: 2}, map[string]Limit{
+		"upload": {Rate: 0.5, Burst: 1},
+		"health": {},
+	}, time.Minute)
+	l.now = func() time.Time { return now }
+
+	tests := []struct {
+		name     string
+		route    string
+		client   string
+		advance  time.Duration
+		expected Result
+	}{
+		{name: "first", route: "read", client: "a", expected: Result{Allowed: true, Limit: 2, Remaining: 1}},
+		{name: "burst", route: "read", client: "a", expected: Result{Allowed: true, Limit: 2, Remaining: 0}},
+		{name: "exhausted", route: "read", client: "a", expected: Result{Limit: 2, RetryAfter: time.Second}},
+		{name: "other client", route: "read", client: "b", expected: Result{Allowed: true, Limit: 2, Remaining: 1}},
+		{name: "refilled", route: "read", client: "a", advance: time.Second, expected: Result{Allowed: true, Limit: 2, Remaining: 0}},
+		{name: "route limit", route: "upload", client: "a", expected: Result{Allowed: true, Limit: 1, Remaining: 0}},
+		{name: "route exhausted", route: "upload", client: "a", expected: Result{Limit: 1, RetryAfter: 2 * time.Second}},
+		{name: "unlimited route", route: "health", client: "a", expected: Result{Allowed: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			assert.Equal(t, tt.expected, l.Allow(tt.route, tt.client))
+		})
+	}
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	l := New(Limit{Rate: 1, Burst: 1}, nil, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Allow("read", "a")
+	now = now.Add(30 * time.Second)
+	l.Allow("read", "b")
+	assert.Len(t, l.buckets, 2)
+
+	now = now.Add(45 * time.Second)
+	l.Allow("read", "c")
+	assert.Len(t, l.buckets, 2)
+	assert.NotContains(t, l.buckets, "read\x00a")
+}
+
+func TestFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+rate_limit:
+  enabled: true
+  rate: 20
+  burst: 40
+  idle_timeout: 10m
+  routes:
+    upload:
+      rate: 1
+      burst: 10
+`), 0o600))
+	cfg := config.New()
+	require.NoError(t, cfg.LoadConfigFiles(path))
+
+	l, err := FromConfig(cfg)
+
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 20, Burst: 40}, l.def)
+	assert.Equal(t, map[string]Limit{"upload": {Rate: 1, Burst: 10}}, l.routes)
+	assert.Equal(t, 10*time.Minute, l.idle)
+
+	l, err = FromConfig(config.New())
+	require.NoError(t, err)
+	assert.Nil(t, l)
+}
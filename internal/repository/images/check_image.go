@@ -5,31 +5,50 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/google/uuid"
 )
 
 const (
 	statusInProcess = "in process"
+	statusProcessed = "processed"
+	statusFailed    = "failed"
 )
 
-func (r *Repository) CheckImage(ctx context.Context, id uint) error {
+// CheckImage returns the image record. ErrImageInProcess or a *FailedError
+// is returned together with the record while the image has no result.
+func (r *Repository) CheckImage(ctx context.Context, id uuid.UUID) (*models.ImageRecord, error) {
+	defer metrics.ObserveDB("check_image", time.Now())
+
 	query := `
-		SELECT status
+		SELECT ` + imageColumns + `
 		FROM image
-		WHERE id = $1;
+		WHERE public_id = $1;
 	`
 
-	var status string
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&status)
+	im, err := scanImage(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return ErrImageNotFound
+			return nil, ErrImageNotFound
 		}
 
-		return fmt.Errorf("repository/check_image.go - failed to check image - %w", err)
+		return nil, fmt.Errorf("repository/check_image.go - failed to check image - %w", err)
 	}
-	if status == statusInProcess {
-		return ErrImageInProcess
+
+	switch im.Status {
+	case statusInProcess:
+		return im, ErrImageInProcess
+	case statusFailed:
+		failedErr := &FailedError{}
+		if im.Error != nil {
+			failedErr.Code, failedErr.Message = im.Error.Code, im.Error.Message
+		}
+		return im, failedErr
 	}
 
-	return nil
+	return im, nil
 }
@@ -0,0 +1,66 @@
+package images
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ClaimIdempotencyKey reserves key of the owner for ttl for the upload with
+// fingerprint. It returns nil once the key is claimed and the record of the
+// upload holding the key otherwise. Expired keys and keys of uploads that did
+// not complete within lockTimeout, typically because the API crashed, are
+// claimed again.
+func (r *Repository) ClaimIdempotencyKey(ctx context.Context, ownerID uint, key, fingerprint string,
+	ttl, lockTimeout time.Duration) (*models.IdempotencyRecord, error) {
+	defer metrics.ObserveDB("claim_idempotency_key", time.Now())
+
+	claim := `
+		INSERT INTO idempotency_key (owner_id, key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, now() + $4 * interval '1 millisecond')
+		ON CONFLICT (owner_id, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, image_id = NULL, status_code = NULL,
+			created_at = now(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_key.expires_at <= now()
+			OR (idempotency_key.status_code IS NULL
+				AND idempotency_key.created_at < now() - $5 * interval '1 millisecond');
+	`
+
+	res, err := r.db.ExecContext(ctx, claim, ownerID, key, fingerprint, ttl.Milliseconds(), lockTimeout.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("repository/claim_idempotency_key.go - failed to claim idempotency key - %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows > 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT fingerprint, image_id, status_code
+		FROM idempotency_key
+		WHERE owner_id = $1 AND key = $2;
+	`
+
+	record := models.IdempotencyRecord{}
+	var imageID uuid.NullUUID
+	var statusCode sql.NullInt64
+	err = r.db.Master.QueryRowContext(ctx, query, ownerID, key).Scan(&record.Fingerprint, &imageID, &statusCode)
+	if err != nil {
+		// The holder gave the key up in the meantime, the client may retry.
+		if errors.Is(err, sql.ErrNoRows) {
+			return &models.IdempotencyRecord{Fingerprint: fingerprint}, nil
+		}
+
+		return nil, fmt.Errorf("repository/claim_idempotency_key.go - failed to get idempotency key - %w", err)
+	}
+	record.ImageID = imageID.UUID
+	record.StatusCode = int(statusCode.Int64)
+
+	return &record, nil
+}
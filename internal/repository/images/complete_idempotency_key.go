@@ -0,0 +1,30 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// CompleteIdempotencyKey stores the response to the upload holding key, it is
+// returned to retries until the key expires.
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, ownerID uint, key string, imageID uuid.UUID, statusCode int) error {
+	defer metrics.ObserveDB("complete_idempotency_key", time.Now())
+
+	query := `
+		UPDATE idempotency_key
+		SET image_id = $3, status_code = $4
+		WHERE owner_id = $1 AND key = $2;
+	`
+
+	_, err := r.db.ExecContext(ctx, query, ownerID, key, imageID, statusCode)
+	if err != nil {
+		return fmt.Errorf("repository/complete_idempotency_key.go - failed to complete idempotency key - %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CompleteImage marks the image as processed and records the dimensions of
+// the original and of the result.
+func (r *Repository) CompleteImage(ctx context.Context, id uuid.UUID, original, result models.ImageInfo) error {
+	defer metrics.ObserveDB("complete_image", time.Now())
+
+	query := `
+		UPDATE image
+		SET status = $2,
+			original_width = $3, original_height = $4,
+			result_content_type = $5, result_width = $6, result_height = $7, result_size = $8,
+			processed_at = now(), updated_at = now(),
+			last_error = NULL, error_code = NULL, error_message = NULL
+		WHERE public_id = $1;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id, statusProcessed,
+		original.Width, original.Height, result.ContentType, result.Width, result.Height, result.Size)
+	if err != nil {
+		return fmt.Errorf("repository/complete_image.go - failed to complete image - %w", err)
+	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+)
+
+// DeleteExpiredIdempotencyKeys removes up to limit expired idempotency keys
+// and returns how many it removed.
+func (r *Repository) DeleteExpiredIdempotencyKeys(ctx context.Context, limit int) (int, error) {
+	defer metrics.ObserveDB("delete_expired_idempotency_keys", time.Now())
+
+	query := `
+		DELETE FROM idempotency_key
+		WHERE (owner_id, key) IN (
+			SELECT owner_id, key
+			FROM idempotency_key
+			WHERE expires_at <= now()
+			LIMIT $1
+		);
+	`
+
+	res, err := r.db.ExecContext(ctx, query, limit)
+	if err != nil {
+		return 0, fmt.Errorf("repository/delete_expired_idempotency_keys.go - failed to delete expired idempotency keys - %w", err)
+	}
+	rows, _ := res.RowsAffected()
+
+	return int(rows), nil
+}
@@ -3,22 +3,29 @@ package images
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/google/uuid"
 )
 
 const (
 	rowsAffected = 0
 )
 
-func (r *Repository) DeleteImage(ctx context.Context, id uint) error {
+func (r *Repository) DeleteImage(ctx context.Context, id uuid.UUID) error {
+	defer metrics.ObserveDB("delete_image", time.Now())
+
 	query := `
 		DELETE
 		FROM image
-		WHERE id = $1;
+		WHERE public_id = $1;
 	`
 
 	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("repository/change_image_status.go - failed to delete image - %w", err)
+		return fmt.Errorf("repository/delete_image.go - failed to delete image - %w", err)
 	}
 	rows, _ := res.RowsAffected()
 	if rows == rowsAffected {
@@ -0,0 +1,33 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+func (r *Repository) FailImage(ctx context.Context, id uuid.UUID, code, message string) error {
+	defer metrics.ObserveDB("fail_image", time.Now())
+
+	query := `
+		UPDATE image
+		SET status = $2, error_code = $3, error_message = $4,
+			last_error = $4, updated_at = now()
+		WHERE public_id = $1;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id, statusFailed, code, message)
+	if err != nil {
+		return fmt.Errorf("repository/fail_image.go - failed to mark image as failed - %w", err)
+	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package images
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+)
+
+// GetQuota returns the limits of an API key together with its usage, the
+// jobs are those counted for the UTC day starting at day. Bytes are counted
+// once per stored object, images sharing the objects of an identical image
+// add nothing. It reads the master, so uploads that just finished are counted.
+func (r *Repository) GetQuota(ctx context.Context, ownerID uint, day time.Time) (*models.Quota, error) {
+	defer metrics.ObserveDB("get_quota", time.Now())
+
+	query := `
+		SELECT api_key.max_images, api_key.max_bytes, api_key.max_jobs_per_day,
+			(SELECT count(*) FROM image WHERE owner_id = api_key.id),
+			COALESCE((
+				SELECT sum(size) FROM (
+					SELECT max(original_size + COALESCE(result_size, 0)) AS size
+					FROM image
+					WHERE owner_id = api_key.id
+					GROUP BY object_id
+				) AS objects
+			), 0),
+			COALESCE((SELECT jobs FROM api_key_usage WHERE owner_id = api_key.id AND day = $2), 0)
+		FROM api_key
+		WHERE api_key.id = $1;
+	`
+
+	quota := models.Quota{}
+	var maxImages, maxBytes, maxJobsPerDay sql.NullInt64
+	err := r.db.Master.QueryRowContext(ctx, query, ownerID, day).Scan(&maxImages, &maxBytes, &maxJobsPerDay,
+		&quota.Images, &quota.Bytes, &quota.JobsToday)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOwnerNotFound
+		}
+
+		return nil, fmt.Errorf("repository/get_quota.go - failed to get quota - %w", err)
+	}
+	quota.MaxImages = limit(maxImages)
+	quota.MaxBytes = limit(maxBytes)
+	quota.MaxJobsPerDay = limit(maxJobsPerDay)
+
+	return &quota, nil
+}
+
+// limit maps a NULL limit to nil, the configured default.
+func limit(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+
+	return &v.Int64
+}
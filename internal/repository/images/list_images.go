@@ -0,0 +1,93 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+)
+
+const sortDesc = "desc"
+
+// ListImages returns up to limit images matching filter ordered by
+// (created_at, public_id). With after set the listing continues behind that image,
+// so pages stay stable while new images are uploaded.
+func (r *Repository) ListImages(ctx context.Context, filter *models.ImageFilter, after *models.ImageCursor, limit int) ([]*models.ImageRecord, error) {
+	defer metrics.ObserveDB("list_images", time.Now())
+
+	var (
+		conds []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.OwnerID != 0 {
+		conds = append(conds, "owner_id = "+arg(filter.OwnerID))
+	}
+	if filter.Status != "" {
+		conds = append(conds, "status = "+arg(filter.Status))
+	}
+	if filter.Processing != "" {
+		processing, err := json.Marshal([]models.Operation{{Op: filter.Processing}})
+		if err != nil {
+			return nil, fmt.Errorf("repository/list_images.go - failed to marshal processing - %w", err)
+		}
+		conds = append(conds, "processing @> "+arg(string(processing))+"::jsonb")
+	}
+	if filter.SourceFormat != "" {
+		conds = append(conds, "source_format = "+arg(filter.SourceFormat))
+	}
+	if filter.CreatedFrom != nil {
+		conds = append(conds, "created_at >= "+arg(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		conds = append(conds, "created_at < "+arg(*filter.CreatedTo))
+	}
+
+	order, cmp := "ASC", ">"
+	if filter.Sort == sortDesc {
+		order, cmp = "DESC", "<"
+	}
+	if after != nil {
+		conds = append(conds, fmt.Sprintf("(created_at, public_id) %s (%s, %s)", cmp, arg(after.CreatedAt), arg(after.ID)))
+	}
+
+	query := `
+		SELECT ` + imageColumns + `
+		FROM image`
+	if len(conds) > 0 {
+		query += `
+		WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += fmt.Sprintf(`
+		ORDER BY created_at %s, public_id %s
+		LIMIT %s;
+	`, order, order, arg(limit))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository/list_images.go - failed to list images - %w", err)
+	}
+	defer rows.Close()
+
+	images := []*models.ImageRecord{}
+	for rows.Next() {
+		im, err := scanImage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository/list_images.go - failed to scan image - %w", err)
+		}
+		images = append(images, im)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository/list_images.go - failed to list images - %w", err)
+	}
+
+	return images, nil
+}
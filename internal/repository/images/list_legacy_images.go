@@ -0,0 +1,44 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+)
+
+// ListLegacyImages returns up to limit images with a serial id above after,
+// ordered by that id, to move their objects to the public id keys.
+func (r *Repository) ListLegacyImages(ctx context.Context, after int64, limit int) ([]models.LegacyImage, error) {
+	defer metrics.ObserveDB("list_legacy_images", time.Now())
+
+	query := `
+		SELECT id, public_id, variant, status = $3
+		FROM image
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, after, limit, statusProcessed)
+	if err != nil {
+		return nil, fmt.Errorf("repository/list_legacy_images.go - failed to list images - %w", err)
+	}
+	defer rows.Close()
+
+	images := []models.LegacyImage{}
+	for rows.Next() {
+		im := models.LegacyImage{}
+		if err := rows.Scan(&im.ID, &im.PublicID, &im.Variant, &im.Processed); err != nil {
+			return nil, fmt.Errorf("repository/list_legacy_images.go - failed to scan image - %w", err)
+		}
+		images = append(images, im)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository/list_legacy_images.go - failed to list images - %w", err)
+	}
+
+	return images, nil
+}
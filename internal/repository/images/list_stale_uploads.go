@@ -0,0 +1,46 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// ListStaleUploads returns images whose job was never released because the
+// upload did not finish within timeout.
+func (r *Repository) ListStaleUploads(ctx context.Context, timeout time.Duration, limit int) ([]uuid.UUID, error) {
+	defer metrics.ObserveDB("list_stale_uploads", time.Now())
+
+	query := `
+		SELECT image.public_id
+		FROM outbox
+		JOIN image ON image.id = outbox.image_id
+		WHERE outbox.ready_at IS NULL AND outbox.created_at < now() - $1 * interval '1 millisecond'
+		ORDER BY outbox.created_at
+		LIMIT $2;
+	`
+
+	rows, err := r.db.Master.QueryContext(ctx, query, timeout.Milliseconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("repository/list_stale_uploads.go - failed to list stale uploads - %w", err)
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("repository/list_stale_uploads.go - failed to scan image id - %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository/list_stale_uploads.go - failed to list stale uploads - %w", err)
+	}
+
+	return ids, nil
+}
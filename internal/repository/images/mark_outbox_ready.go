@@ -0,0 +1,34 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// MarkOutboxReady releases the job of the image to the relay once the
+// original is stored.
+func (r *Repository) MarkOutboxReady(ctx context.Context, id uuid.UUID) error {
+	defer metrics.ObserveDB("mark_outbox_ready", time.Now())
+
+	query := `
+		UPDATE outbox
+		SET ready_at = now()
+		WHERE image_id = (SELECT id FROM image WHERE public_id = $1) AND ready_at IS NULL;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("repository/mark_outbox_ready.go - failed to mark outbox message ready - %w", err)
+	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
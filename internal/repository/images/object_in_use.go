@@ -0,0 +1,29 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// ObjectInUse reports whether an image still stores its objects under
+// objectID. It reads the master, so an image deleted just before is not
+// counted.
+func (r *Repository) ObjectInUse(ctx context.Context, objectID uuid.UUID) (bool, error) {
+	defer metrics.ObserveDB("object_in_use", time.Now())
+
+	query := `
+		SELECT EXISTS (SELECT 1 FROM image WHERE object_id = $1);
+	`
+
+	var inUse bool
+	if err := r.db.Master.QueryRowContext(ctx, query, objectID).Scan(&inUse); err != nil {
+		return false, fmt.Errorf("repository/object_in_use.go - failed to check object references - %w", err)
+	}
+
+	return inUse, nil
+}
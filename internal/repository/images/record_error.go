@@ -0,0 +1,34 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// RecordError stores the message of a failed attempt that is going to be
+// retried, the image stays in process.
+func (r *Repository) RecordError(ctx context.Context, id uuid.UUID, message string) error {
+	defer metrics.ObserveDB("record_error", time.Now())
+
+	query := `
+		UPDATE image
+		SET last_error = $2, updated_at = now()
+		WHERE public_id = $1;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id, message)
+	if err != nil {
+		return fmt.Errorf("repository/record_error.go - failed to record error - %w", err)
+	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// RelayOutbox locks up to limit ready messages, passes them to publish and
+// deletes them when publish succeeds. Locked rows are skipped, so several
+// relays can run side by side. It returns the number of relayed messages.
+func (r *Repository) RelayOutbox(ctx context.Context, limit int, publish func([]models.OutboxMessage) error) (int, error) {
+	defer metrics.ObserveDB("relay_outbox", time.Now())
+
+	selectQuery := `
+		SELECT outbox.id, image.public_id, outbox.payload
+		FROM outbox
+		JOIN image ON image.id = outbox.image_id
+		WHERE outbox.ready_at IS NOT NULL
+		ORDER BY outbox.id
+		LIMIT $1
+		FOR UPDATE OF outbox SKIP LOCKED;
+	`
+	deleteQuery := `
+		DELETE
+		FROM outbox
+		WHERE id = ANY($1);
+	`
+
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("repository/relay_outbox.go - failed to begin transaction - %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, selectQuery, limit)
+	if err != nil {
+		return 0, fmt.Errorf("repository/relay_outbox.go - failed to select outbox messages - %w", err)
+	}
+	messages := []models.OutboxMessage{}
+	for rows.Next() {
+		msg := models.OutboxMessage{}
+		if err := rows.Scan(&msg.ID, &msg.ImageID, &msg.Payload); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("repository/relay_outbox.go - failed to scan outbox message - %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("repository/relay_outbox.go - failed to select outbox messages - %w", err)
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(messages); err != nil {
+		return 0, fmt.Errorf("repository/relay_outbox.go - failed to publish outbox messages - %w", err)
+	}
+
+	ids := make([]int64, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+	}
+	if _, err := tx.ExecContext(ctx, deleteQuery, pq.Array(ids)); err != nil {
+		return 0, fmt.Errorf("repository/relay_outbox.go - failed to delete outbox messages - %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("repository/relay_outbox.go - failed to commit transaction - %w", err)
+	}
+
+	return len(messages), nil
+}
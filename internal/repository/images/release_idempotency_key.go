@@ -0,0 +1,27 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+)
+
+// ReleaseIdempotencyKey gives up key after the upload holding it failed, so
+// a retry runs the upload again.
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, ownerID uint, key string) error {
+	defer metrics.ObserveDB("release_idempotency_key", time.Now())
+
+	query := `
+		DELETE FROM idempotency_key
+		WHERE owner_id = $1 AND key = $2 AND status_code IS NULL;
+	`
+
+	_, err := r.db.ExecContext(ctx, query, ownerID, key)
+	if err != nil {
+		return fmt.Errorf("repository/release_idempotency_key.go - failed to release idempotency key - %w", err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package images
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/wb-go/wbf/dbpg"
 )
@@ -9,8 +10,25 @@ import (
 var (
 	ErrImageNotFound  = errors.New("image not found")
 	ErrImageInProcess = errors.New("image in process")
+	ErrImageFailed    = errors.New("image processing failed")
+	ErrOwnerNotFound  = errors.New("api key not found")
 )
 
+// FailedError is returned by CheckImage for images whose processing failed.
+// It matches ErrImageFailed with errors.Is.
+type FailedError struct {
+	Code    string
+	Message string
+}
+
+func (e *FailedError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrImageFailed, e.Code, e.Message)
+}
+
+func (e *FailedError) Is(target error) bool {
+	return target == ErrImageFailed
+}
+
 type Repository struct {
 	db *dbpg.DB
 }
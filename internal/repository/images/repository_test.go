@@ -5,41 +5,150 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/models"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/wb-go/wbf/dbpg"
 )
 
+const checkImageQuery = `SELECT public_id, status, variant, source_format, processing, ` +
+	`original_filename, original_content_type, original_width, original_height, original_size, ` +
+	`result_content_type, result_width, result_height, result_size, ` +
+	`attempts, created_at, updated_at, processed_at, last_error, error_code, error_message, owner_id, ` +
+	`object_id, content_hash, spec_hash FROM image WHERE public_id = \$1`
+
+var (
+	imageID = uuid.MustParse("0193f1c2-7a40-7cc1-9a3e-2f1d0c4b5a61")
+	otherID = uuid.MustParse("0193f1c2-7a40-7cc1-9a3e-2f1d0c4b5a62")
+	thirdID = uuid.MustParse("0193f1c2-7a40-7cc1-9a3e-2f1d0c4b5a63")
+)
+
+const (
+	contentHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	specHash    = "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+)
+
+var imageColumnNames = []string{
+	"public_id", "status", "variant", "source_format", "processing",
+	"original_filename", "original_content_type", "original_width", "original_height", "original_size",
+	"result_content_type", "result_width", "result_height", "result_size",
+	"attempts", "created_at", "updated_at", "processed_at", "last_error", "error_code", "error_message", "owner_id",
+	"object_id", "content_hash", "spec_hash",
+}
+
+func newImageRecord() *models.ImageRecord {
+	return &models.ImageRecord{
+		Status:       "in process",
+		Variant:      "processed",
+		SourceFormat: "png",
+		Processing:   []models.Operation{{Op: "grayscale"}},
+		Original:     models.ImageInfo{FileName: "cat.png", ContentType: "image/png", Size: 1024},
+		ContentHash:  contentHash,
+		SpecHash:     specHash,
+	}
+}
+
 func TestRepository_SetImageStatus(t *testing.T) {
+	insertImage := `INSERT INTO image \(public_id, object_id, status, variant, source_format, processing, original_filename, original_content_type, original_size, owner_id, content_hash, spec_hash\) VALUES \(\$1, \$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9, \$10, \$11\) RETURNING id`
+	insertOutbox := `INSERT INTO outbox \(image_id, payload\) VALUES \(\$1, \$2\)`
+	countJob := `INSERT INTO api_key_usage \(owner_id, day, jobs\) VALUES \(\$1, \$2, 1\) ON CONFLICT \(owner_id, day\) DO UPDATE SET jobs = api_key_usage.jobs \+ 1`
+	payload := []byte(`{"pipeline":[{"op":"grayscale"}],"variant":"processed"}`)
+
 	tests := []struct {
 		name        string
-		status      string
+		image       *models.ImageRecord
 		mockSetup   func(sqlmock.Sqlmock)
-		expectedID  uint
 		expectError bool
 	}{
 		{
-			name:   "success",
-			status: "in process",
+			name:  "success",
+			image: newImageRecord(),
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`INSERT INTO image \(status\) VALUES \(\$1\) RETURNING id`).
-					WithArgs("in process").
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertImage).
+					WithArgs(sqlmock.AnyArg(), "in process", "processed", "png", []byte(`[{"op":"grayscale"}]`), "cat.png", "image/png", int64(1024), nil, contentHash, specHash).
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(insertOutbox).
+					WithArgs(1, payload).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
 			},
-			expectedID:  1,
 			expectError: false,
 		},
 		{
-			name:   "db error",
-			status: "in process",
+			name: "owned",
+			image: func() *models.ImageRecord {
+				im := newImageRecord()
+				im.OwnerID = 7
+				return im
+			}(),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertImage).
+					WithArgs(sqlmock.AnyArg(), "in process", "processed", "png", []byte(`[{"op":"grayscale"}]`), "cat.png", "image/png", int64(1024), uint(7), contentHash, specHash).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				mock.ExpectExec(insertOutbox).
+					WithArgs(2, payload).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(countJob).
+					WithArgs(uint(7), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectError: false,
+		},
+		{
+			name: "usage error",
+			image: func() *models.ImageRecord {
+				im := newImageRecord()
+				im.OwnerID = 7
+				return im
+			}(),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertImage).
+					WithArgs(sqlmock.AnyArg(), "in process", "processed", "png", []byte(`[{"op":"grayscale"}]`), "cat.png", "image/png", int64(1024), uint(7), contentHash, specHash).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				mock.ExpectExec(insertOutbox).
+					WithArgs(2, payload).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(countJob).
+					WithArgs(uint(7), sqlmock.AnyArg()).
+					WillReturnError(errors.New("db error"))
+				mock.ExpectRollback()
+			},
+			expectError: true,
+		},
+		{
+			name:  "db error",
+			image: newImageRecord(),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertImage).
+					WithArgs(sqlmock.AnyArg(), "in process", "processed", "png", []byte(`[{"op":"grayscale"}]`), "cat.png", "image/png", int64(1024), nil, contentHash, specHash).
+					WillReturnError(errors.New("db error"))
+				mock.ExpectRollback()
+			},
+			expectError: true,
+		},
+		{
+			name:  "outbox error",
+			image: newImageRecord(),
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`INSERT INTO image \(status\) VALUES \(\$1\) RETURNING id`).
-					WithArgs("in process").
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertImage).
+					WithArgs(sqlmock.AnyArg(), "in process", "processed", "png", []byte(`[{"op":"grayscale"}]`), "cat.png", "image/png", int64(1024), nil, contentHash, specHash).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(insertOutbox).
+					WithArgs(1, payload).
 					WillReturnError(errors.New("db error"))
+				mock.ExpectRollback()
 			},
-			expectedID:  0,
 			expectError: true,
 		},
 	}
@@ -54,14 +163,14 @@ func TestRepository_SetImageStatus(t *testing.T) {
 
 			repo := &Repository{db: &dbpg.DB{Master: db}}
 
-			id, err := repo.SetImageStatus(context.Background(), tt.status)
+			id, err := repo.SetImageStatus(context.Background(), tt.image, payload)
 
 			if tt.expectError {
 				assert.Error(t, err)
-				assert.Equal(t, tt.expectedID, id)
+				assert.Equal(t, uuid.Nil, id)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.expectedID, id)
+				assert.Equal(t, uuid.Version(7), id.Version())
 			}
 
 			assert.NoError(t, mock.ExpectationsWereMet())
@@ -70,48 +179,97 @@ func TestRepository_SetImageStatus(t *testing.T) {
 }
 
 func TestRepository_CheckImage(t *testing.T) {
+	createdAt := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	processedAt := createdAt.Add(time.Second)
+	pipeline := []models.Operation{{Op: "grayscale"}}
+
 	tests := []struct {
 		name        string
-		id          uint
+		id          uuid.UUID
 		mockSetup   func(sqlmock.Sqlmock)
 		expectError error
+		expected    *models.ImageRecord
 	}{
 		{
 			name: "success - processed",
-			id:   1,
+			id:   imageID,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT status FROM image WHERE id = \$1`).
-					WithArgs(1).
-					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("processed"))
+				mock.ExpectQuery(checkImageQuery).
+					WithArgs(imageID).
+					WillReturnRows(sqlmock.NewRows(imageColumnNames).AddRow(
+						imageID.String(), "processed", "processed", "png", []byte(`[{"op":"grayscale"}]`),
+						"cat.png", "image/png", 640, 480, 1024, "image/png", 320, 240, 512,
+						1, createdAt, processedAt, processedAt, nil, nil, nil, nil,
+						imageID.String(), contentHash, specHash,
+					))
 			},
 			expectError: nil,
+			expected: &models.ImageRecord{
+				ID: imageID, Status: "processed", Variant: "processed", SourceFormat: "png", Processing: pipeline,
+				Original: models.ImageInfo{FileName: "cat.png", ContentType: "image/png", Width: 640, Height: 480, Size: 1024},
+				Result:   &models.ImageInfo{ContentType: "image/png", Width: 320, Height: 240, Size: 512},
+				Attempts: 1, CreatedAt: createdAt, UpdatedAt: processedAt, ProcessedAt: &processedAt,
+				ObjectID: imageID, ContentHash: contentHash, SpecHash: specHash,
+			},
 		},
 		{
 			name: "not found",
-			id:   1,
+			id:   imageID,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT status FROM image WHERE id = \$1`).
-					WithArgs(1).
+				mock.ExpectQuery(checkImageQuery).
+					WithArgs(imageID).
 					WillReturnError(sql.ErrNoRows)
 			},
 			expectError: ErrImageNotFound,
 		},
 		{
 			name: "in process",
-			id:   1,
+			id:   imageID,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT status FROM image WHERE id = \$1`).
-					WithArgs(1).
-					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("in process"))
+				mock.ExpectQuery(checkImageQuery).
+					WithArgs(imageID).
+					WillReturnRows(sqlmock.NewRows(imageColumnNames).AddRow(
+						imageID.String(), "in process", "processed", "png", []byte(`[{"op":"grayscale"}]`),
+						"cat.png", "image/png", nil, nil, 1024, nil, nil, nil, nil,
+						0, createdAt, createdAt, nil, nil, nil, nil, nil,
+						imageID.String(), nil, nil,
+					))
 			},
 			expectError: ErrImageInProcess,
+			expected: &models.ImageRecord{
+				ID: imageID, Status: "in process", Variant: "processed", SourceFormat: "png", Processing: pipeline,
+				Original:  models.ImageInfo{FileName: "cat.png", ContentType: "image/png", Size: 1024},
+				CreatedAt: createdAt, UpdatedAt: createdAt, ObjectID: imageID,
+			},
+		},
+		{
+			name: "failed",
+			id:   imageID,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(checkImageQuery).
+					WithArgs(imageID).
+					WillReturnRows(sqlmock.NewRows(imageColumnNames).AddRow(
+						imageID.String(), "failed", "processed", "png", []byte(`[{"op":"grayscale"}]`),
+						"cat.png", "image/png", nil, nil, 1024, nil, nil, nil, nil,
+						1, createdAt, processedAt, nil, "unexpected EOF", "decode_failed", "unexpected EOF", nil,
+						imageID.String(), nil, nil,
+					))
+			},
+			expectError: &FailedError{Code: "decode_failed", Message: "unexpected EOF"},
+			expected: &models.ImageRecord{
+				ID: imageID, Status: "failed", Variant: "processed", SourceFormat: "png", Processing: pipeline,
+				Original: models.ImageInfo{FileName: "cat.png", ContentType: "image/png", Size: 1024},
+				Attempts: 1, CreatedAt: createdAt, UpdatedAt: processedAt, LastError: "unexpected EOF",
+				Error:    &models.ImageError{Code: "decode_failed", Message: "unexpected EOF"},
+				ObjectID: imageID,
+			},
 		},
 		{
 			name: "db error",
-			id:   1,
+			id:   imageID,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT status FROM image WHERE id = \$1`).
-					WithArgs(1).
+				mock.ExpectQuery(checkImageQuery).
+					WithArgs(imageID).
 					WillReturnError(errors.New("db error"))
 			},
 			expectError: errors.New("repository/check_image.go - failed to check image - db error"),
@@ -128,7 +286,7 @@ func TestRepository_CheckImage(t *testing.T) {
 
 			repo := &Repository{db: &dbpg.DB{Master: db}}
 
-			err = repo.CheckImage(context.Background(), tt.id)
+			im, err := repo.CheckImage(context.Background(), tt.id)
 
 			if tt.expectError != nil {
 				assert.Error(t, err)
@@ -136,6 +294,7 @@ func TestRepository_CheckImage(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 			}
+			assert.Equal(t, tt.expected, im)
 
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
@@ -145,39 +304,39 @@ func TestRepository_CheckImage(t *testing.T) {
 func TestRepository_DeleteImage(t *testing.T) {
 	tests := []struct {
 		name        string
-		id          uint
+		id          uuid.UUID
 		mockSetup   func(sqlmock.Sqlmock)
 		expectError error
 	}{
 		{
 			name: "success",
-			id:   1,
+			id:   imageID,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec(`DELETE FROM image WHERE id = \$1`).
-					WithArgs(1).
+				mock.ExpectExec(`DELETE FROM image WHERE public_id = \$1`).
+					WithArgs(imageID).
 					WillReturnResult(sqlmock.NewResult(0, 1))
 			},
 			expectError: nil,
 		},
 		{
 			name: "not found",
-			id:   1,
+			id:   imageID,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec(`DELETE FROM image WHERE id = \$1`).
-					WithArgs(1).
+				mock.ExpectExec(`DELETE FROM image WHERE public_id = \$1`).
+					WithArgs(imageID).
 					WillReturnResult(sqlmock.NewResult(0, 0))
 			},
 			expectError: ErrImageNotFound,
 		},
 		{
 			name: "db error",
-			id:   1,
+			id:   imageID,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec(`DELETE FROM image WHERE id = \$1`).
-					WithArgs(1).
+				mock.ExpectExec(`DELETE FROM image WHERE public_id = \$1`).
+					WithArgs(imageID).
 					WillReturnError(errors.New("db error"))
 			},
-			expectError: errors.New("repository/change_image_status.go - failed to delete image - db error"),
+			expectError: errors.New("repository/delete_image.go - failed to delete image - db error"),
 		},
 	}
 
@@ -205,46 +364,848 @@ func TestRepository_DeleteImage(t *testing.T) {
 	}
 }
 
-func TestRepository_ChangeImageStatus(t *testing.T) {
+func TestRepository_FailImage(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          uuid.UUID
+		code        string
+		message     string
+		mockSetup   func(sqlmock.Sqlmock)
+		expectError error
+	}{
+		{
+			name:    "success",
+			id:      imageID,
+			code:    "decode_failed",
+			message: "unexpected EOF",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE image SET status = \$2, error_code = \$3, error_message = \$4, last_error = \$4, updated_at = now\(\) WHERE public_id = \$1`).
+					WithArgs(imageID, "failed", "decode_failed", "unexpected EOF").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectError: nil,
+		},
+		{
+			name:    "not found",
+			id:      imageID,
+			code:    "decode_failed",
+			message: "unexpected EOF",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE image SET status = \$2, error_code = \$3, error_message = \$4, last_error = \$4, updated_at = now\(\) WHERE public_id = \$1`).
+					WithArgs(imageID, "failed", "decode_failed", "unexpected EOF").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectError: ErrImageNotFound,
+		},
+		{
+			name:    "db error",
+			id:      imageID,
+			code:    "decode_failed",
+			message: "unexpected EOF",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE image SET status = \$2, error_code = \$3, error_message = \$4, last_error = \$4, updated_at = now\(\) WHERE public_id = \$1`).
+					WithArgs(imageID, "failed", "decode_failed", "unexpected EOF").
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/fail_image.go - failed to mark image as failed - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+
+			err = repo.FailImage(context.Background(), tt.id, tt.code, tt.message)
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_CompleteImage(t *testing.T) {
+	original := models.ImageInfo{Width: 640, Height: 480}
+	result := models.ImageInfo{ContentType: "image/png", Width: 320, Height: 240, Size: 512}
+
 	tests := []struct {
 		name        string
-		id          uint
-		status      string
+		id          uuid.UUID
 		mockSetup   func(sqlmock.Sqlmock)
 		expectError error
 	}{
 		{
-			name:   "success",
-			id:     1,
-			status: "processed",
+			name: "success",
+			id:   imageID,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec(`UPDATE image SET status = \$2 WHERE id = \$1`).
-					WithArgs(1, "processed").
+				mock.ExpectExec(`UPDATE image SET status = \$2, original_width = \$3, original_height = \$4, result_content_type = \$5, result_width = \$6, result_height = \$7, result_size = \$8, processed_at = now\(\), updated_at = now\(\), last_error = NULL, error_code = NULL, error_message = NULL WHERE public_id = \$1`).
+					WithArgs(imageID, "processed", 640, 480, "image/png", 320, 240, int64(512)).
 					WillReturnResult(sqlmock.NewResult(0, 1))
 			},
 			expectError: nil,
 		},
 		{
-			name:   "not found",
-			id:     1,
-			status: "processed",
+			name: "not found",
+			id:   imageID,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec(`UPDATE image SET status = \$2 WHERE id = \$1`).
-					WithArgs(1, "processed").
+				mock.ExpectExec(`UPDATE image SET status = \$2`).
+					WithArgs(imageID, "processed", 640, 480, "image/png", 320, 240, int64(512)).
 					WillReturnResult(sqlmock.NewResult(0, 0))
 			},
 			expectError: ErrImageNotFound,
 		},
+		{
+			name: "db error",
+			id:   imageID,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE image SET status = \$2`).
+					WithArgs(imageID, "processed", 640, 480, "image/png", 320, 240, int64(512)).
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/complete_image.go - failed to complete image - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+
+			err = repo.CompleteImage(context.Background(), tt.id, original, result)
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_StartAttempt(t *testing.T) {
+	tests := []struct {
+		name             string
+		id               uuid.UUID
+		mockSetup        func(sqlmock.Sqlmock)
+		expectedAttempts int
+		expectError      error
+	}{
+		{
+			name: "success",
+			id:   imageID,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`UPDATE image SET attempts = attempts \+ 1, updated_at = now\(\) WHERE public_id = \$1 RETURNING attempts`).
+					WithArgs(imageID).
+					WillReturnRows(sqlmock.NewRows([]string{"attempts"}).AddRow(2))
+			},
+			expectedAttempts: 2,
+			expectError:      nil,
+		},
+		{
+			name: "not found",
+			id:   imageID,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`UPDATE image SET attempts = attempts \+ 1`).
+					WithArgs(imageID).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectError: ErrImageNotFound,
+		},
+		{
+			name: "db error",
+			id:   imageID,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`UPDATE image SET attempts = attempts \+ 1`).
+					WithArgs(imageID).
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/start_attempt.go - failed to start attempt - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+
+			attempts, err := repo.StartAttempt(context.Background(), tt.id)
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedAttempts, attempts)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_ListImages(t *testing.T) {
+	createdAt := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	createdTo := createdAt.Add(time.Hour)
+	listQuery := `SELECT public_id, status, variant, source_format, processing, .* FROM image`
+
+	tests := []struct {
+		name        string
+		filter      *models.ImageFilter
+		after       *models.ImageCursor
+		limit       int
+		mockSetup   func(sqlmock.Sqlmock)
+		expectedIDs []uuid.UUID
+		expectError error
+	}{
+		{
+			name:   "no filters",
+			filter: &models.ImageFilter{Sort: "asc"},
+			limit:  3,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery + ` ORDER BY created_at ASC, public_id ASC LIMIT \$1`).
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows(imageColumnNames).
+						AddRow(imageID.String(), "processed", "processed", "png", []byte(`[{"op":"grayscale"}]`),
+							"a.png", "image/png", 640, 480, 1024, "image/png", 640, 480, 512,
+							1, createdAt, createdAt, createdAt, nil, nil, nil, nil, imageID.String(), nil, nil).
+						AddRow(otherID.String(), "in process", "processed", "jpeg", []byte(`[{"op":"resize","width":300}]`),
+							"b.jpg", "image/jpeg", nil, nil, 2048, nil, nil, nil, nil,
+							0, createdAt, createdAt, nil, nil, nil, nil, nil, otherID.String(), nil, nil))
+			},
+			expectedIDs: []uuid.UUID{imageID, otherID},
+		},
+		{
+			name: "filters and cursor",
+			filter: &models.ImageFilter{
+				Status: "failed", Processing: "resize", SourceFormat: "png",
+				CreatedFrom: &createdAt, CreatedTo: &createdTo, Sort: "desc",
+			},
+			after: &models.ImageCursor{CreatedAt: createdAt, ID: imageID},
+			limit: 2,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery+` WHERE status = \$1 AND processing @> \$2::jsonb AND source_format = \$3 `+
+					`AND created_at >= \$4 AND created_at < \$5 AND \(created_at, public_id\) < \(\$6, \$7\) `+
+					`ORDER BY created_at DESC, public_id DESC LIMIT \$8`).
+					WithArgs("failed", `[{"op":"resize"}]`, "png", createdAt, createdTo, createdAt, imageID, 2).
+					WillReturnRows(sqlmock.NewRows(imageColumnNames))
+			},
+			expectedIDs: []uuid.UUID{},
+		},
+		{
+			name:   "owner",
+			filter: &models.ImageFilter{OwnerID: 7, Sort: "desc"},
+			limit:  2,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery+` WHERE owner_id = \$1 ORDER BY created_at DESC, public_id DESC LIMIT \$2`).
+					WithArgs(uint(7), 2).
+					WillReturnRows(sqlmock.NewRows(imageColumnNames).
+						AddRow(thirdID.String(), "processed", "processed", "png", []byte(`[{"op":"grayscale"}]`),
+							"c.png", "image/png", 640, 480, 1024, "image/png", 640, 480, 512,
+							1, createdAt, createdAt, createdAt, nil, nil, nil, 7, thirdID.String(), nil, nil))
+			},
+			expectedIDs: []uuid.UUID{thirdID},
+		},
 		{
 			name:   "db error",
-			id:     1,
-			status: "processed",
+			filter: &models.ImageFilter{Sort: "desc"},
+			limit:  1,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery).
+					WithArgs(1).
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/list_images.go - failed to list images - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+
+			images, err := repo.ListImages(context.Background(), tt.filter, tt.after, tt.limit)
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+				ids := []uuid.UUID{}
+				for _, im := range images {
+					ids = append(ids, im.ID)
+				}
+				assert.Equal(t, tt.expectedIDs, ids)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_RecordError(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          uuid.UUID
+		message     string
+		mockSetup   func(sqlmock.Sqlmock)
+		expectError error
+	}{
+		{
+			name:    "success",
+			id:      imageID,
+			message: "connection refused",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE image SET last_error = \$2, updated_at = now\(\) WHERE public_id = \$1`).
+					WithArgs(imageID, "connection refused").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectError: nil,
+		},
+		{
+			name:    "not found",
+			id:      imageID,
+			message: "connection refused",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE image SET last_error = \$2, updated_at = now\(\) WHERE public_id = \$1`).
+					WithArgs(imageID, "connection refused").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectError: ErrImageNotFound,
+		},
+		{
+			name:    "db error",
+			id:      imageID,
+			message: "connection refused",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE image SET last_error = \$2, updated_at = now\(\) WHERE public_id = \$1`).
+					WithArgs(imageID, "connection refused").
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/record_error.go - failed to record error - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+
+			err = repo.RecordError(context.Background(), tt.id, tt.message)
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_MarkOutboxReady(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          uuid.UUID
+		mockSetup   func(sqlmock.Sqlmock)
+		expectError error
+	}{
+		{
+			name: "success",
+			id:   imageID,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE outbox SET ready_at = now\(\) WHERE image_id = \(SELECT id FROM image WHERE public_id = \$1\) AND ready_at IS NULL`).
+					WithArgs(imageID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectError: nil,
+		},
+		{
+			name: "not found",
+			id:   imageID,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE outbox SET ready_at = now\(\)`).
+					WithArgs(imageID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectError: ErrImageNotFound,
+		},
+		{
+			name: "db error",
+			id:   imageID,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE outbox SET ready_at = now\(\)`).
+					WithArgs(imageID).
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/mark_outbox_ready.go - failed to mark outbox message ready - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+
+			err = repo.MarkOutboxReady(context.Background(), tt.id)
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_RelayOutbox(t *testing.T) {
+	selectOutbox := `SELECT outbox.id, image.public_id, outbox.payload FROM outbox JOIN image ON image.id = outbox.image_id ` +
+		`WHERE outbox.ready_at IS NOT NULL ORDER BY outbox.id LIMIT \$1 FOR UPDATE OF outbox SKIP LOCKED`
+	deleteOutbox := `DELETE FROM outbox WHERE id = ANY\(\$1\)`
+
+	tests := []struct {
+		name        string
+		publishErr  error
+		mockSetup   func(sqlmock.Sqlmock)
+		expectedN   int
+		expectError error
+	}{
+		{
+			name: "success",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectOutbox).
+					WithArgs(10).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "payload"}).
+						AddRow(1, imageID.String(), []byte(`{}`)).
+						AddRow(2, otherID.String(), []byte(`{}`)))
+				mock.ExpectExec(deleteOutbox).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			},
+			expectedN: 2,
+		},
+		{
+			name: "empty outbox",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectOutbox).
+					WithArgs(10).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "payload"}))
+				mock.ExpectRollback()
+			},
+			expectedN: 0,
+		},
+		{
+			name:       "publish error keeps messages",
+			publishErr: errors.New("kafka down"),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectOutbox).
+					WithArgs(10).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "payload"}).
+						AddRow(1, imageID.String(), []byte(`{}`)))
+				mock.ExpectRollback()
+			},
+			expectError: errors.New("repository/relay_outbox.go - failed to publish outbox messages - kafka down"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+
+			var published []models.OutboxMessage
+			n, err := repo.RelayOutbox(context.Background(), 10, func(messages []models.OutboxMessage) error {
+				published = messages
+				return tt.publishErr
+			})
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedN, len(published))
+				if len(published) > 0 {
+					assert.Equal(t, imageID, published[0].ImageID)
+				}
+			}
+			assert.Equal(t, tt.expectedN, n)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_ListLegacyImages(t *testing.T) {
+	listQuery := `SELECT id, public_id, variant, status = \$3 FROM image WHERE id > \$1 ORDER BY id LIMIT \$2`
+
+	tests := []struct {
+		name        string
+		mockSetup   func(sqlmock.Sqlmock)
+		expected    []models.LegacyImage
+		expectError error
+	}{
+		{
+			name: "success",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery).
+					WithArgs(int64(5), 2, "processed").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "variant", "processed"}).
+						AddRow(6, imageID.String(), "processed", true).
+						AddRow(9, otherID.String(), "thumb", false))
+			},
+			expected: []models.LegacyImage{
+				{ID: 6, PublicID: imageID, Variant: "processed", Processed: true},
+				{ID: 9, PublicID: otherID, Variant: "thumb"},
+			},
+		},
+		{
+			name: "db error",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery).
+					WithArgs(int64(5), 2, "processed").
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/list_legacy_images.go - failed to list images - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+
+			images, err := repo.ListLegacyImages(context.Background(), 5, 2)
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, images)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_GetQuota(t *testing.T) {
+	since := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	quotaQuery := `SELECT api_key.max_images, api_key.max_bytes, api_key.max_jobs_per_day, ` +
+		`\(SELECT count\(\*\) FROM image WHERE owner_id = api_key.id\), ` +
+		`COALESCE\(\( SELECT sum\(size\) FROM \( SELECT max\(original_size \+ COALESCE\(result_size, 0\)\) AS size ` +
+		`FROM image WHERE owner_id = api_key.id GROUP BY object_id \) AS objects \), 0\), ` +
+		`COALESCE\(\(SELECT jobs FROM api_key_usage WHERE owner_id = api_key.id AND day = \$2\), 0\) FROM api_key ` +
+		`WHERE api_key.id = \$1`
+	maxImages := int64(100)
+
+	tests := []struct {
+		name        string
+		mockSetup   func(sqlmock.Sqlmock)
+		expected    *models.Quota
+		expectError error
+	}{
+		{
+			name: "success",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(quotaQuery).
+					WithArgs(uint(7), since).
+					WillReturnRows(sqlmock.NewRows([]string{"max_images", "max_bytes", "max_jobs_per_day", "count", "sum", "count"}).
+						AddRow(100, nil, nil, 3, 4096, 2))
+			},
+			expected: &models.Quota{
+				QuotaLimits: models.QuotaLimits{MaxImages: &maxImages},
+				Images:      3, Bytes: 4096, JobsToday: 2,
+			},
+		},
+		{
+			name: "unknown key",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(quotaQuery).
+					WithArgs(uint(7), since).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectError: ErrOwnerNotFound,
+		},
+		{
+			name: "db error",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(quotaQuery).
+					WithArgs(uint(7), since).
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/get_quota.go - failed to get quota - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+
+			quota, err := repo.GetQuota(context.Background(), 7, since)
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, quota)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_ClaimIdempotencyKey(t *testing.T) {
+	claimQuery := `INSERT INTO idempotency_key \(owner_id, key, fingerprint, expires_at\)`
+	recordQuery := `SELECT fingerprint, image_id, status_code FROM idempotency_key WHERE owner_id = \$1 AND key = \$2`
+
+	tests := []struct {
+		name        string
+		mockSetup   func(sqlmock.Sqlmock)
+		expected    *models.IdempotencyRecord
+		expectError error
+	}{
+		{
+			name: "claimed",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(claimQuery).
+					WithArgs(uint(7), "retry", "fp", int64(86400000), int64(600000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "completed",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(claimQuery).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(recordQuery).
+					WithArgs(uint(7), "retry").
+					WillReturnRows(sqlmock.NewRows([]string{"fingerprint", "image_id", "status_code"}).
+						AddRow("fp", imageID.String(), 201))
+			},
+			expected: &models.IdempotencyRecord{Fingerprint: "fp", ImageID: imageID, StatusCode: 201},
+		},
+		{
+			name: "in progress",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(claimQuery).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(recordQuery).
+					WithArgs(uint(7), "retry").
+					WillReturnRows(sqlmock.NewRows([]string{"fingerprint", "image_id", "status_code"}).
+						AddRow("other", nil, nil))
+			},
+			expected: &models.IdempotencyRecord{Fingerprint: "other"},
+		},
+		{
+			name: "released meanwhile",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(claimQuery).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(recordQuery).
+					WithArgs(uint(7), "retry").
+					WillReturnError(sql.ErrNoRows)
+			},
+			expected: &models.IdempotencyRecord{Fingerprint: "fp"},
+		},
+		{
+			name: "db error",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(claimQuery).
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/claim_idempotency_key.go - failed to claim idempotency key - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+
+			record, err := repo.ClaimIdempotencyKey(context.Background(), 7, "retry", "fp", 24*time.Hour, 10*time.Minute)
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, record)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_ShareImage(t *testing.T) {
+	shareQuery := `INSERT INTO image \(public_id, object_id, status, .*\) SELECT \$1, object_id, status, .* FROM image ` +
+		`WHERE \(owner_id = \$3 OR \(owner_id IS NULL AND \$3 IS NULL\)\) AND content_hash = \$4 AND spec_hash = \$5 AND status = \$6 LIMIT 1 FOR SHARE`
+
+	tests := []struct {
+		name        string
+		mockSetup   func(sqlmock.Sqlmock)
+		expectError error
+	}{
+		{
+			name: "shared",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(shareQuery).
+					WithArgs(sqlmock.AnyArg(), "cat.png", uint(7), contentHash, specHash, "processed").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "no duplicate",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(shareQuery).
+					WithArgs(sqlmock.AnyArg(), "cat.png", uint(7), contentHash, specHash, "processed").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectError: ErrImageNotFound,
+		},
+		{
+			name: "db error",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(shareQuery).
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/share_image.go - failed to share image - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			repo := &Repository{db: &dbpg.DB{Master: db}}
+			im := newImageRecord()
+			im.OwnerID = 7
+
+			id, err := repo.ShareImage(context.Background(), im)
+
+			if tt.expectError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError.Error())
+				assert.Equal(t, uuid.Nil, id)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, uuid.Version(7), id.Version())
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_ShareResult(t *testing.T) {
+	shareQuery := `UPDATE image SET object_id = src.object_id, status = src.status, .* FROM \(.* FOR SHARE OF dup \) AS src ` +
+		`WHERE image.public_id = \$1 AND image.status <> \$2`
+
+	tests := []struct {
+		name        string
+		mockSetup   func(sqlmock.Sqlmock)
+		expectError error
+	}{
+		{
+			name: "shared",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(shareQuery).
+					WithArgs(imageID, "processed").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "no duplicate",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(shareQuery).
+					WithArgs(imageID, "processed").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectError: ErrImageNotFound,
+		},
+		{
+			name: "db error",
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec(`UPDATE image SET status = \$2 WHERE id = \$1`).
-					WithArgs(1, "processed").
+				mock.ExpectExec(shareQuery).
+					WithArgs(imageID, "processed").
 					WillReturnError(errors.New("db error"))
 			},
-			expectError: errors.New("repository/change_image_status.go - failed to change image status - db error"),
+			expectError: errors.New("repository/share_result.go - failed to share result - db error"),
 		},
 	}
 
@@ -258,7 +1219,7 @@ func TestRepository_ChangeImageStatus(t *testing.T) {
 
 			repo := &Repository{db: &dbpg.DB{Master: db}}
 
-			err = repo.ChangeImageStatus(context.Background(), tt.id, tt.status)
+			err = repo.ShareResult(context.Background(), imageID)
 
 			if tt.expectError != nil {
 				assert.Error(t, err)
@@ -0,0 +1,83 @@
+package images
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/avraam311/image-processor/internal/models"
+)
+
+// imageColumns lists the columns read by scanImage, in order.
+const imageColumns = `public_id, status, variant, source_format, processing,
+		original_filename, original_content_type, original_width, original_height, original_size,
+		result_content_type, result_width, result_height, result_size,
+		attempts, created_at, updated_at, processed_at, last_error, error_code, error_message, owner_id,
+		object_id, content_hash, spec_hash`
+
+// ownerArg stores anonymous uploads with a NULL owner.
+func ownerArg(ownerID uint) any {
+	if ownerID == 0 {
+		return nil
+	}
+
+	return ownerID
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanImage(row rowScanner) (*models.ImageRecord, error) {
+	im := models.ImageRecord{}
+	var (
+		processing                            []byte
+		originalWidth, originalHeight         sql.NullInt64
+		resultWidth, resultHeight, resultSize sql.NullInt64
+		resultContentType                     sql.NullString
+		processedAt                           sql.NullTime
+		lastError, errorCode, errorMessage    sql.NullString
+		ownerID                               sql.NullInt64
+		contentHash, specHash                 sql.NullString
+	)
+	err := row.Scan(
+		&im.ID, &im.Status, &im.Variant, &im.SourceFormat, &processing,
+		&im.Original.FileName, &im.Original.ContentType, &originalWidth, &originalHeight, &im.Original.Size,
+		&resultContentType, &resultWidth, &resultHeight, &resultSize,
+		&im.Attempts, &im.CreatedAt, &im.UpdatedAt, &processedAt, &lastError, &errorCode, &errorMessage, &ownerID,
+		&im.ObjectID, &contentHash, &specHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(processing) > 0 {
+		if err := json.Unmarshal(processing, &im.Processing); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal processing - %w", err)
+		}
+	}
+	im.Original.Width, im.Original.Height = int(originalWidth.Int64), int(originalHeight.Int64)
+	if resultSize.Valid {
+		im.Result = &models.ImageInfo{
+			ContentType: resultContentType.String,
+			Width:       int(resultWidth.Int64),
+			Height:      int(resultHeight.Int64),
+			Size:        resultSize.Int64,
+		}
+	}
+	if processedAt.Valid {
+		im.ProcessedAt = &processedAt.Time
+	}
+	im.LastError = lastError.String
+	if errorCode.Valid {
+		im.Error = &models.ImageError{
+			Code:    errorCode.String,
+			Message: errorMessage.String,
+		}
+	}
+
+	im.OwnerID = uint(ownerID.Int64)
+	im.ContentHash, im.SpecHash = contentHash.String, specHash.String
+
+	return &im, nil
+}
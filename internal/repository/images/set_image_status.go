@@ -2,21 +2,72 @@ package images
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/google/uuid"
 )
 
-func (r *Repository) SetImageStatus(ctx context.Context, status string) (uint, error) {
-	query := `
-		INSERT INTO image (status)
-		VALUES ($1)
+// SetImageStatus inserts the image under a new UUIDv7, which its objects are
+// stored under as well, together with its job in the outbox, in one
+// transaction. The job is published only after MarkOutboxReady. The job is
+// counted in the daily usage of the owner in the same transaction.
+func (r *Repository) SetImageStatus(ctx context.Context, im *models.ImageRecord, payload []byte) (uuid.UUID, error) {
+	defer metrics.ObserveDB("set_image_status", time.Now())
+
+	imageQuery := `
+		INSERT INTO image (public_id, object_id, status, variant, source_format, processing,
+			original_filename, original_content_type, original_size, owner_id, content_hash, spec_hash)
+		VALUES ($1, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id;
 	`
+	outboxQuery := `
+		INSERT INTO outbox (image_id, payload)
+		VALUES ($1, $2);
+	`
+	usageQuery := `
+		INSERT INTO api_key_usage (owner_id, day, jobs)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (owner_id, day) DO UPDATE SET jobs = api_key_usage.jobs + 1;
+	`
+
+	publicID, err := uuid.NewV7()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("repository/set_image_status.go - failed to generate id - %w", err)
+	}
+	processing, err := json.Marshal(im.Processing)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("repository/set_image_status.go - failed to marshal processing - %w", err)
+	}
+
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("repository/set_image_status.go - failed to begin transaction - %w", err)
+	}
+	defer tx.Rollback()
 
-	var id uint
-	err := r.db.QueryRowContext(ctx, query, status).Scan(&id)
+	var id int64
+	err = tx.QueryRowContext(ctx, imageQuery, publicID, im.Status, im.Variant, im.SourceFormat, processing,
+		im.Original.FileName, im.Original.ContentType, im.Original.Size, ownerArg(im.OwnerID), im.ContentHash, im.SpecHash).Scan(&id)
 	if err != nil {
-		return 0, fmt.Errorf("repository/set_image_status.go - failed to scan id")
+		return uuid.Nil, fmt.Errorf("repository/set_image_status.go - failed to scan id - %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, outboxQuery, id, payload); err != nil {
+		return uuid.Nil, fmt.Errorf("repository/set_image_status.go - failed to insert outbox message - %w", err)
+	}
+	if im.OwnerID != 0 {
+		day := time.Now().UTC().Truncate(24 * time.Hour)
+		if _, err := tx.ExecContext(ctx, usageQuery, im.OwnerID, day); err != nil {
+			return uuid.Nil, fmt.Errorf("repository/set_image_status.go - failed to count job - %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("repository/set_image_status.go - failed to commit transaction - %w", err)
 	}
 
-	return id, nil
+	return publicID, nil
 }
@@ -0,0 +1,55 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ShareImage inserts the image as processed under a new UUIDv7 when the
+// owner already has a processed image with the same content and spec hashes.
+// The new image shares the objects and the result of that one and gets no
+// job. ErrImageNotFound is returned when there is no such image.
+func (r *Repository) ShareImage(ctx context.Context, im *models.ImageRecord) (uuid.UUID, error) {
+	defer metrics.ObserveDB("share_image", time.Now())
+
+	// The source row is locked, so deleting it waits until the new image
+	// references its objects. The owner is compared with = and IS NULL
+	// rather than IS NOT DISTINCT FROM, which can't use an index.
+	query := `
+		INSERT INTO image (public_id, object_id, status, variant, source_format, processing,
+			original_filename, original_content_type, original_width, original_height, original_size,
+			result_content_type, result_width, result_height, result_size,
+			processed_at, owner_id, content_hash, spec_hash)
+		SELECT $1, object_id, status, variant, source_format, processing,
+			$2, original_content_type, original_width, original_height, original_size,
+			result_content_type, result_width, result_height, result_size,
+			now(), owner_id, content_hash, spec_hash
+		FROM image
+		WHERE (owner_id = $3 OR (owner_id IS NULL AND $3 IS NULL)) AND content_hash = $4 AND spec_hash = $5 AND status = $6
+		LIMIT 1
+		FOR SHARE;
+	`
+
+	publicID, err := uuid.NewV7()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("repository/share_image.go - failed to generate id - %w", err)
+	}
+
+	res, err := r.db.ExecContext(ctx, query, publicID, im.Original.FileName, ownerArg(im.OwnerID),
+		im.ContentHash, im.SpecHash, statusProcessed)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("repository/share_image.go - failed to share image - %w", err)
+	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return uuid.Nil, ErrImageNotFound
+	}
+
+	return publicID, nil
+}
@@ -0,0 +1,52 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// ShareResult completes the unprocessed image with the result of a processed
+// image of the same owner with the same content and spec hashes, whose
+// objects it shares from then on. ErrImageNotFound is returned when there is
+// no such image.
+func (r *Repository) ShareResult(ctx context.Context, id uuid.UUID) error {
+	defer metrics.ObserveDB("share_result", time.Now())
+
+	query := `
+		UPDATE image
+		SET object_id = src.object_id, status = src.status,
+			original_width = src.original_width, original_height = src.original_height,
+			result_content_type = src.result_content_type, result_width = src.result_width,
+			result_height = src.result_height, result_size = src.result_size,
+			processed_at = now(), updated_at = now(),
+			last_error = NULL, error_code = NULL, error_message = NULL
+		FROM (
+			SELECT dup.object_id, dup.status, dup.original_width, dup.original_height,
+				dup.result_content_type, dup.result_width, dup.result_height, dup.result_size
+			FROM image AS dup
+			JOIN image AS self ON self.public_id = $1
+			WHERE (dup.owner_id = self.owner_id OR (dup.owner_id IS NULL AND self.owner_id IS NULL))
+				AND dup.content_hash = self.content_hash
+				AND dup.spec_hash = self.spec_hash AND dup.status = $2
+			LIMIT 1
+			FOR SHARE OF dup
+		) AS src
+		WHERE image.public_id = $1 AND image.status <> $2;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id, statusProcessed)
+	if err != nil {
+		return fmt.Errorf("repository/share_result.go - failed to share result - %w", err)
+	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package images
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// StartAttempt counts a new processing attempt and returns the attempt number.
+func (r *Repository) StartAttempt(ctx context.Context, id uuid.UUID) (int, error) {
+	defer metrics.ObserveDB("start_attempt", time.Now())
+
+	query := `
+		UPDATE image
+		SET attempts = attempts + 1, updated_at = now()
+		WHERE public_id = $1
+		RETURNING attempts;
+	`
+
+	var attempts int
+	err := r.db.Master.QueryRowContext(ctx, query, id).Scan(&attempts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrImageNotFound
+		}
+
+		return 0, fmt.Errorf("repository/start_attempt.go - failed to start attempt - %w", err)
+	}
+
+	return attempts, nil
+}
@@ -0,0 +1,29 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+)
+
+// CreateKey stores a key by its hash and returns the stored record.
+func (r *Repository) CreateKey(ctx context.Context, name, prefix, hash string) (*models.APIKey, error) {
+	defer metrics.ObserveDB("create_key", time.Now())
+
+	query := `
+		INSERT INTO api_key (name, prefix, key_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at;
+	`
+
+	key := models.APIKey{Name: name, Prefix: prefix}
+	err := r.db.Master.QueryRowContext(ctx, query, name, prefix, hash).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("repository/create_key.go - failed to insert api key - %w", err)
+	}
+
+	return &key, nil
+}
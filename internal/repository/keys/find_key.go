@@ -0,0 +1,35 @@
+package keys
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+)
+
+// FindKey returns the key with the given hash. Revoked keys are not found.
+func (r *Repository) FindKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	defer metrics.ObserveDB("find_key", time.Now())
+
+	query := `
+		SELECT id, name, prefix, created_at
+		FROM api_key
+		WHERE key_hash = $1 AND revoked_at IS NULL;
+	`
+
+	key := models.APIKey{}
+	err := r.db.QueryRowContext(ctx, query, hash).Scan(&key.ID, &key.Name, &key.Prefix, &key.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrKeyNotFound
+		}
+
+		return nil, fmt.Errorf("repository/find_key.go - failed to find api key - %w", err)
+	}
+
+	return &key, nil
+}
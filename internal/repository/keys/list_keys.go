@@ -0,0 +1,64 @@
+package keys
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+)
+
+// ListKeys returns every key, revoked ones included, oldest first.
+func (r *Repository) ListKeys(ctx context.Context) ([]*models.APIKey, error) {
+	defer metrics.ObserveDB("list_keys", time.Now())
+
+	query := `
+		SELECT id, name, prefix, created_at, revoked_at, max_images, max_bytes, max_jobs_per_day
+		FROM api_key
+		ORDER BY id;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("repository/list_keys.go - failed to list api keys - %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key := models.APIKey{}
+		var (
+			revokedAt                          sql.NullTime
+			maxImages, maxBytes, maxJobsPerDay sql.NullInt64
+		)
+		err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.CreatedAt, &revokedAt, &maxImages, &maxBytes, &maxJobsPerDay)
+		if err != nil {
+			return nil, fmt.Errorf("repository/list_keys.go - failed to scan api key - %w", err)
+		}
+		if revokedAt.Valid {
+			key.RevokedAt = &revokedAt.Time
+		}
+		key.Quota = models.QuotaLimits{
+			MaxImages:     limit(maxImages),
+			MaxBytes:      limit(maxBytes),
+			MaxJobsPerDay: limit(maxJobsPerDay),
+		}
+		keys = append(keys, &key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository/list_keys.go - failed to iterate api keys - %w", err)
+	}
+
+	return keys, nil
+}
+
+// limit maps a NULL limit to nil, the configured default.
+func limit(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+
+	return &v.Int64
+}
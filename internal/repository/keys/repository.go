@@ -0,0 +1,21 @@
+package keys
+
+import (
+	"errors"
+
+	"github.com/wb-go/wbf/dbpg"
+)
+
+var (
+	ErrKeyNotFound = errors.New("api key not found")
+)
+
+type Repository struct {
+	db *dbpg.DB
+}
+
+func NewRepository(db *dbpg.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
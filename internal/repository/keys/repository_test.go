@@ -0,0 +1,207 @@
+package keys
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wb-go/wbf/dbpg"
+)
+
+const hash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func newTestRepository(t *testing.T) (*Repository, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return &Repository{db: &dbpg.DB{Master: db}}, mock
+}
+
+func TestRepository_CreateKey(t *testing.T) {
+	createdAt := time.Date(2025, 12, 25, 12, 0, 0, 0, time.UTC)
+	repo, mock := newTestRepository(t)
+	mock.ExpectQuery(`INSERT INTO api_key \(name, prefix, key_hash\) VALUES \(\$1, \$2, \$3\) RETURNING id, created_at`).
+		WithArgs("ci", "ip_abcd", hash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, createdAt))
+
+	key, err := repo.CreateKey(context.Background(), "ci", "ip_abcd", hash)
+
+	require.NoError(t, err)
+	assert.Equal(t, &models.APIKey{ID: 1, Name: "ci", Prefix: "ip_abcd", CreatedAt: createdAt}, key)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_FindKey(t *testing.T) {
+	createdAt := time.Date(2025, 12, 25, 12, 0, 0, 0, time.UTC)
+	findQuery := `SELECT id, name, prefix, created_at FROM api_key WHERE key_hash = \$1 AND revoked_at IS NULL`
+
+	tests := []struct {
+		name        string
+		mockSetup   func(sqlmock.Sqlmock)
+		expected    *models.APIKey
+		expectError error
+	}{
+		{
+			name: "success",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(findQuery).
+					WithArgs(hash).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "created_at"}).
+						AddRow(1, "ci", "ip_abcd", createdAt))
+			},
+			expected: &models.APIKey{ID: 1, Name: "ci", Prefix: "ip_abcd", CreatedAt: createdAt},
+		},
+		{
+			name: "unknown or revoked",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(findQuery).
+					WithArgs(hash).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectError: ErrKeyNotFound,
+		},
+		{
+			name: "db error",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(findQuery).
+					WithArgs(hash).
+					WillReturnError(errors.New("db error"))
+			},
+			expectError: errors.New("repository/find_key.go - failed to find api key - db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock := newTestRepository(t)
+			tt.mockSetup(mock)
+
+			key, err := repo.FindKey(context.Background(), hash)
+
+			if tt.expectError != nil {
+				assert.EqualError(t, err, tt.expectError.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, key)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_ListKeys(t *testing.T) {
+	createdAt := time.Date(2025, 12, 25, 12, 0, 0, 0, time.UTC)
+	revokedAt := createdAt.Add(time.Hour)
+	maxImages := int64(100)
+	repo, mock := newTestRepository(t)
+	mock.ExpectQuery(`SELECT id, name, prefix, created_at, revoked_at, max_images, max_bytes, max_jobs_per_day FROM api_key ORDER BY id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "created_at", "revoked_at", "max_images", "max_bytes", "max_jobs_per_day"}).
+			AddRow(1, "ci", "ip_abcd", createdAt, revokedAt, nil, nil, nil).
+			AddRow(2, "web", "ip_efgh", createdAt, nil, 100, nil, nil))
+
+	keys, err := repo.ListKeys(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []*models.APIKey{
+		{ID: 1, Name: "ci", Prefix: "ip_abcd", CreatedAt: createdAt, RevokedAt: &revokedAt},
+		{ID: 2, Name: "web", Prefix: "ip_efgh", CreatedAt: createdAt, Quota: models.QuotaLimits{MaxImages: &maxImages}},
+	}, keys)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_RevokeKey(t *testing.T) {
+	revokeQuery := `UPDATE api_key SET revoked_at = COALESCE\(revoked_at, now\(\)\) WHERE id = \$1`
+
+	tests := []struct {
+		name        string
+		mockSetup   func(sqlmock.Sqlmock)
+		expectError error
+	}{
+		{
+			name: "success",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(revokeQuery).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "not found",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(revokeQuery).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectError: ErrKeyNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock := newTestRepository(t)
+			tt.mockSetup(mock)
+
+			err := repo.RevokeKey(context.Background(), 1)
+
+			if tt.expectError != nil {
+				assert.ErrorIs(t, err, tt.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_SetQuota(t *testing.T) {
+	setQuery := `UPDATE api_key SET max_images = \$2, max_bytes = \$3, max_jobs_per_day = \$4 WHERE id = \$1`
+	maxImages, maxJobs := int64(100), int64(0)
+
+	tests := []struct {
+		name        string
+		mockSetup   func(sqlmock.Sqlmock)
+		expectError error
+	}{
+		{
+			name: "success",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(setQuery).
+					WithArgs(1, int64(100), nil, int64(0)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "not found",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(setQuery).
+					WithArgs(1, int64(100), nil, int64(0)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectError: ErrKeyNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock := newTestRepository(t)
+			tt.mockSetup(mock)
+
+			err := repo.SetQuota(context.Background(), 1, models.QuotaLimits{MaxImages: &maxImages, MaxJobsPerDay: &maxJobs})
+
+			if tt.expectError != nil {
+				assert.ErrorIs(t, err, tt.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
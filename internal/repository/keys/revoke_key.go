@@ -0,0 +1,34 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+)
+
+// RevokeKey disables a key for good. Revoking a revoked key is a no-op.
+func (r *Repository) RevokeKey(ctx context.Context, id uint) error {
+	defer metrics.ObserveDB("revoke_key", time.Now())
+
+	query := `
+		UPDATE api_key
+		SET revoked_at = COALESCE(revoked_at, now())
+		WHERE id = $1;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("repository/revoke_key.go - failed to revoke api key - %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("repository/revoke_key.go - failed to get affected rows - %w", err)
+	}
+	if n == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
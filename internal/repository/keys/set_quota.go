@@ -0,0 +1,36 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+)
+
+// SetQuota replaces the limits of a key, nil limits fall back to the
+// configured defaults.
+func (r *Repository) SetQuota(ctx context.Context, id uint, limits models.QuotaLimits) error {
+	defer metrics.ObserveDB("set_quota", time.Now())
+
+	query := `
+		UPDATE api_key
+		SET max_images = $2, max_bytes = $3, max_jobs_per_day = $4
+		WHERE id = $1;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id, limits.MaxImages, limits.MaxBytes, limits.MaxJobsPerDay)
+	if err != nil {
+		return fmt.Errorf("repository/set_quota.go - failed to set quota - %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("repository/set_quota.go - failed to get affected rows - %w", err)
+	}
+	if n == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package images
+
+import (
+	"context"
+
+	"github.com/avraam311/image-processor/internal/auth"
+	"github.com/avraam311/image-processor/internal/models"
+	"github.com/avraam311/image-processor/internal/repository/images"
+
+	"github.com/google/uuid"
+)
+
+// checkImage is repo.CheckImage restricted to the images of the API key of
+// the request. Images of other keys are reported as not found, so their ids
+// reveal nothing.
+func (s *Service) checkImage(ctx context.Context, id uuid.UUID) (*models.ImageRecord, error) {
+	im, err := s.repo.CheckImage(ctx, id)
+	if owner, ok := auth.Owner(ctx); ok && im != nil && im.OwnerID != owner {
+		return nil, images.ErrImageNotFound
+	}
+
+	return im, err
+}
@@ -0,0 +1,68 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	QuotaImages     = "images"
+	QuotaBytes      = "bytes"
+	QuotaJobsPerDay = "jobs_per_day"
+)
+
+// QuotaError reports the quota an upload would exceed. RetryAfter is the time
+// until the quota resets, 0 when only deleting images frees it. It matches
+// ErrQuotaExceeded with errors.Is.
+type QuotaError struct {
+	Quota      string
+	Limit      int64
+	Used       int64
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: %s: %d of %d used", ErrQuotaExceeded, e.Quota, e.Used, e.Limit)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// checkQuota rejects an upload of size bytes when it would take the API key
+// over one of its quotas. The check isn't atomic with the upload, concurrent
+// uploads of one key may overshoot by the uploads in flight.
+func (s *Service) checkQuota(ctx context.Context, owner uint, size int64) error {
+	now := time.Now().UTC()
+	day := now.Truncate(24 * time.Hour)
+	quota, err := s.repo.GetQuota(ctx, owner, day)
+	if err != nil {
+		return err
+	}
+
+	maxImages := s.quotaLimit(quota.MaxImages, "quota.max_images")
+	if maxImages > 0 && quota.Images+1 > maxImages {
+		return &QuotaError{Quota: QuotaImages, Limit: maxImages, Used: quota.Images}
+	}
+	maxBytes := s.quotaLimit(quota.MaxBytes, "quota.max_bytes")
+	if maxBytes > 0 && quota.Bytes+size > maxBytes {
+		return &QuotaError{Quota: QuotaBytes, Limit: maxBytes, Used: quota.Bytes}
+	}
+	maxJobs := s.quotaLimit(quota.MaxJobsPerDay, "quota.max_jobs_per_day")
+	if maxJobs > 0 && quota.JobsToday+1 > maxJobs {
+		return &QuotaError{Quota: QuotaJobsPerDay, Limit: maxJobs, Used: quota.JobsToday, RetryAfter: day.Add(24 * time.Hour).Sub(now)}
+	}
+
+	return nil
+}
+
+// quotaLimit is the limit of the key, or the configured default when the key
+// has none.
+func (s *Service) quotaLimit(limit *int64, key string) int64 {
+	if limit != nil {
+		return *limit
+	}
+
+	return s.cfg.GetInt64(key)
+}
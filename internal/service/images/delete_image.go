@@ -2,14 +2,47 @@ package images
 
 import (
 	"context"
+	"errors"
 	"fmt"
+
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/repository/images"
+
+	"github.com/google/uuid"
 )
 
-func (s *Service) DeleteImage(ctx context.Context, id uint) error {
-	err := s.repo.DeleteImage(ctx, id)
+// DeleteImage deletes the image and its objects, unless other images still
+// share them.
+func (s *Service) DeleteImage(ctx context.Context, id uuid.UUID) error {
+	im, err := s.checkImage(ctx, id)
+	if err != nil && !errors.Is(err, images.ErrImageInProcess) && !errors.Is(err, images.ErrImageFailed) {
+		return fmt.Errorf("service/images - %w", err)
+	}
+
+	err = s.repo.DeleteImage(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service/images - %w", err)
+	}
+
+	inUse, err := s.repo.ObjectInUse(ctx, im.ObjectID)
 	if err != nil {
 		return fmt.Errorf("service/images - %w", err)
 	}
+	if inUse {
+		return nil
+	}
+
+	variants, err := s.store.List(ctx, storage.VariantsPrefix(im.ObjectID))
+	if err != nil {
+		return fmt.Errorf("service/images - %w", err)
+	}
+	keys := []string{storage.OriginalKey(im.ObjectID)}
+	for _, variant := range variants {
+		keys = append(keys, variant.Key)
+	}
+	if err := s.store.Delete(ctx, keys...); err != nil {
+		return fmt.Errorf("service/images - %w", err)
+	}
 
 	return nil
 }
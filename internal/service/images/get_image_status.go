@@ -0,0 +1,21 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/avraam311/image-processor/internal/models"
+	"github.com/avraam311/image-processor/internal/repository/images"
+
+	"github.com/google/uuid"
+)
+
+func (s *Service) GetImageStatus(ctx context.Context, id uuid.UUID) (*models.ImageRecord, error) {
+	im, err := s.checkImage(ctx, id)
+	if err != nil && !errors.Is(err, images.ErrImageInProcess) && !errors.Is(err, images.ErrImageFailed) {
+		return nil, fmt.Errorf("service/images - %w", err)
+	}
+
+	return im, nil
+}
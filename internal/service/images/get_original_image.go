@@ -0,0 +1,22 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/models"
+	"github.com/avraam311/image-processor/internal/repository/images"
+
+	"github.com/google/uuid"
+)
+
+func (s *Service) GetOriginalImage(ctx context.Context, id uuid.UUID) (*models.ImageObject, error) {
+	im, err := s.checkImage(ctx, id)
+	if err != nil && !errors.Is(err, images.ErrImageInProcess) && !errors.Is(err, images.ErrImageFailed) {
+		return nil, fmt.Errorf("service/images - %w", err)
+	}
+
+	return s.getObject(ctx, storage.OriginalKey(im.ObjectID))
+}
@@ -1,33 +1,46 @@
 package images
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"strconv"
 
-	"github.com/minio/minio-go"
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/google/uuid"
 )
 
-func (s *Service) GetProcessedImage(ctx context.Context, id uint) ([]byte, error) {
-	err := s.repo.CheckImage(ctx, id)
+func (s *Service) GetProcessedImage(ctx context.Context, id uuid.UUID) (*models.ImageObject, error) {
+	im, err := s.checkImage(ctx, id)
 	if err != nil {
-		return []byte{}, fmt.Errorf("service/images - %w", err)
+		return nil, fmt.Errorf("service/images - %w", err)
 	}
 
-	getObjectOptions := minio.GetObjectOptions{}
-	objectName := strconv.Itoa(int(id))
-	object, err := s.s3.Minio.GetObject(s.cfg.GetString("s3.bucket_name"), objectName, getObjectOptions)
+	return s.getObject(ctx, storage.VariantKey(im.ObjectID, im.Variant))
+}
+
+func (s *Service) getObject(ctx context.Context, objectName string) (*models.ImageObject, error) {
+	object, info, err := s.store.Get(ctx, objectName)
 	if err != nil {
-		return []byte{}, fmt.Errorf("service/images - failed to get image from s3 - %w", err)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrVariantNotFound
+		}
+
+		return nil, fmt.Errorf("service/images - failed to get image from storage - %w", err)
 	}
 	defer object.Close()
-	buf := new(bytes.Buffer)
-	if _, err = io.Copy(buf, object); err != nil {
-		return nil, fmt.Errorf("service/images - failed to copy object into buffer - %w", err)
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, fmt.Errorf("service/images - failed to read image from storage - %w", err)
 	}
-	image := buf.Bytes()
 
-	return image, nil
+	return &models.ImageObject{
+		Data:         data,
+		ContentType:  info.ContentType,
+		Size:         info.Size,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}, nil
 }
@@ -0,0 +1,59 @@
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/avraam311/image-processor/internal/models"
+)
+
+// hashUpload sets the content hash of the upload, the SHA-256 of the image,
+// and its spec hash, the SHA-256 of its processing job. The image is read to
+// the end and rewound, or buffered in memory when it can't seek.
+func hashUpload(im *models.Image) error {
+	hash := sha256.New()
+	if seeker, ok := im.File.(io.ReadSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("failed to seek image - %w", err)
+		}
+		if _, err := io.Copy(hash, seeker); err != nil {
+			return fmt.Errorf("failed to read image - %w", err)
+		}
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind image - %w", err)
+		}
+	} else {
+		data, err := io.ReadAll(im.File)
+		if err != nil {
+			return fmt.Errorf("failed to read image - %w", err)
+		}
+		hash.Write(data)
+		im.File = bytes.NewReader(data)
+	}
+	im.ContentHash = hex.EncodeToString(hash.Sum(nil))
+
+	payload, err := jobPayload(im)
+	if err != nil {
+		return err
+	}
+	spec := sha256.Sum256(payload)
+	im.SpecHash = hex.EncodeToString(spec[:])
+
+	return nil
+}
+
+// jobPayload is the job of the upload. Pipelines are built by the handler
+// from whatever the client sent, so equal processing gives equal payloads.
+func jobPayload(im *models.Image) ([]byte, error) {
+	payload, err := json.Marshal(models.ImageKafka{Pipeline: im.Pipeline, Variant: im.Variant})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pipeline into json - %w", err)
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,61 @@
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+// claimIdempotencyKey claims the idempotency key of an upload. It returns
+// nil when the upload should run and the stored result when the key belongs
+// to a completed upload of the same image and processing.
+func (s *Service) claimIdempotencyKey(ctx context.Context, owner uint, im *models.Image) (*models.UploadResult, error) {
+	fingerprint := fingerprint(im)
+
+	// An upload still holding its key after outbox.upload_timeout is removed
+	// by the outbox relay, so the key is free again as well.
+	record, err := s.repo.ClaimIdempotencyKey(ctx, owner, im.IdempotencyKey, fingerprint,
+		s.cfg.GetDuration("idempotency.ttl"), s.cfg.GetDuration("outbox.upload_timeout"))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case record == nil:
+		return nil, nil
+	case record.Fingerprint != fingerprint:
+		return nil, ErrIdempotencyKeyReused
+	case record.StatusCode == 0:
+		return nil, ErrIdempotencyKeyInUse
+	}
+
+	return &models.UploadResult{ID: record.ImageID, StatusCode: record.StatusCode, Replayed: true}, nil
+}
+
+// finishIdempotencyKey stores the result of the upload holding an
+// idempotency key, or gives the key up when the upload failed so that a
+// retry runs it again.
+func (s *Service) finishIdempotencyKey(ctx context.Context, owner uint, key string, res *models.UploadResult, uploadErr error) {
+	ctx = context.WithoutCancel(ctx)
+	if uploadErr != nil {
+		if err := s.repo.ReleaseIdempotencyKey(ctx, owner, key); err != nil {
+			zlog.Logger.Warn().Err(err).Str("key", key).Msg("service/idempotency_key.go - failed to release idempotency key")
+		}
+		return
+	}
+
+	if err := s.repo.CompleteIdempotencyKey(ctx, owner, key, res.ID, res.StatusCode); err != nil {
+		zlog.Logger.Warn().Err(err).Str("key", key).Stringer("image", res.ID).Msg("service/idempotency_key.go - failed to complete idempotency key")
+	}
+}
+
+// fingerprint identifies the image and processing of an upload.
+func fingerprint(im *models.Image) string {
+	hash := sha256.Sum256([]byte(im.ContentHash + im.SpecHash))
+
+	return hex.EncodeToString(hash[:])
+}
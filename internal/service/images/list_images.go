@@ -0,0 +1,70 @@
+package images
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/avraam311/image-processor/internal/auth"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ListImages returns one page of images. One extra row is requested to know
+// whether another page follows; the cursor is opaque to clients.
+func (s *Service) ListImages(ctx context.Context, filter *models.ImageFilter) (*models.ImagePage, error) {
+	var after *models.ImageCursor
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("service/images - %w", err)
+		}
+		after = cursor
+	}
+
+	if owner, ok := auth.Owner(ctx); ok {
+		filter.OwnerID = owner
+	}
+
+	images, err := s.repo.ListImages(ctx, filter, after, filter.Limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("service/images - %w", err)
+	}
+
+	page := models.ImagePage{Images: images}
+	if len(images) > filter.Limit {
+		page.Images = images[:filter.Limit]
+		last := page.Images[len(page.Images)-1]
+		page.NextCursor, err = encodeCursor(&models.ImageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		if err != nil {
+			return nil, fmt.Errorf("service/images - %w", err)
+		}
+	}
+
+	return &page, nil
+}
+
+func encodeCursor(cursor *models.ImageCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor - %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value string) (*models.ImageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := models.ImageCursor{}
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
@@ -2,31 +2,55 @@ package images
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/wb-go/wbf/config"
-	"github.com/wb-go/wbf/kafka"
 
-	"github.com/avraam311/image-processor/internal/infra/minio"
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrVariantNotFound   = errors.New("variant not found")
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrImageTooLarge     = errors.New("image too large")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrQuotaExceeded     = errors.New("quota exceeded")
+	// ErrIdempotencyKeyReused is returned for an idempotency key already used
+	// by an upload with a different image or processing.
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+	// ErrIdempotencyKeyInUse is returned while the first upload with an
+	// idempotency key is still running.
+	ErrIdempotencyKeyInUse = errors.New("idempotency key in use by a running request")
 )
 
 type Repository interface {
-	SetImageStatus(context.Context, string) (uint, error)
-	CheckImage(context.Context, uint) error
-	DeleteImage(context.Context, uint) error
+	SetImageStatus(context.Context, *models.ImageRecord, []byte) (uuid.UUID, error)
+	ShareImage(context.Context, *models.ImageRecord) (uuid.UUID, error)
+	MarkOutboxReady(context.Context, uuid.UUID) error
+	CheckImage(context.Context, uuid.UUID) (*models.ImageRecord, error)
+	ListImages(context.Context, *models.ImageFilter, *models.ImageCursor, int) ([]*models.ImageRecord, error)
+	DeleteImage(context.Context, uuid.UUID) error
+	ObjectInUse(context.Context, uuid.UUID) (bool, error)
+	GetQuota(context.Context, uint, time.Time) (*models.Quota, error)
+	ClaimIdempotencyKey(context.Context, uint, string, string, time.Duration, time.Duration) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(context.Context, uint, string, uuid.UUID, int) error
+	ReleaseIdempotencyKey(context.Context, uint, string) error
 }
 
 type Service struct {
-	repo Repository
-	prod *kafka.Producer
-	cfg  *config.Config
-	s3   *minio.Minio
+	repo  Repository
+	cfg   *config.Config
+	store storage.ObjectStore
 }
 
-func NewService(repo Repository, prod *kafka.Producer, cfg *config.Config, s3 *minio.Minio) *Service {
+func NewService(repo Repository, cfg *config.Config, store storage.ObjectStore) *Service {
 	return &Service{
-		repo: repo,
-		prod: prod,
-		cfg:  cfg,
-		s3:   s3,
+		repo:  repo,
+		cfg:   cfg,
+		store: store,
 	}
 }
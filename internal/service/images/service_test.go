@@ -0,0 +1,612 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avraam311/image-processor/internal/auth"
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/models"
+	"github.com/avraam311/image-processor/internal/repository/images"
+
+	"github.com/wb-go/wbf/config"
+	"github.com/wb-go/wbf/dbpg"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+// fakeRepository keeps images in a map, enough to exercise the service with
+// an in-memory object store.
+type fakeRepository struct {
+	images    map[uuid.UUID]*models.ImageRecord
+	ready     map[uuid.UUID]bool
+	readyErr  error
+	deleteErr error
+	filter    *models.ImageFilter
+	quota     models.Quota
+	keys      map[string]*models.IdempotencyRecord
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		images: make(map[uuid.UUID]*models.ImageRecord),
+		ready:  make(map[uuid.UUID]bool),
+		keys:   make(map[string]*models.IdempotencyRecord),
+	}
+}
+
+func (r *fakeRepository) SetImageStatus(ctx context.Context, im *models.ImageRecord, payload []byte) (uuid.UUID, error) {
+	record := *im
+	record.ID = uuid.Must(uuid.NewV7())
+	record.ObjectID = record.ID
+	r.images[record.ID] = &record
+	return record.ID, nil
+}
+
+func (r *fakeRepository) ShareImage(ctx context.Context, im *models.ImageRecord) (uuid.UUID, error) {
+	for _, src := range r.images {
+		if src.Status == "processed" && src.OwnerID == im.OwnerID &&
+			src.ContentHash == im.ContentHash && src.SpecHash == im.SpecHash {
+			record := *src
+			record.ID = uuid.Must(uuid.NewV7())
+			record.Original.FileName = im.Original.FileName
+			r.images[record.ID] = &record
+			return record.ID, nil
+		}
+	}
+	return uuid.Nil, images.ErrImageNotFound
+}
+
+func (r *fakeRepository) ObjectInUse(ctx context.Context, objectID uuid.UUID) (bool, error) {
+	for _, im := range r.images {
+		if im.ObjectID == objectID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRepository) MarkOutboxReady(ctx context.Context, id uuid.UUID) error {
+	if r.readyErr != nil {
+		return r.readyErr
+	}
+	r.ready[id] = true
+	return nil
+}
+
+func (r *fakeRepository) CheckImage(ctx context.Context, id uuid.UUID) (*models.ImageRecord, error) {
+	im, ok := r.images[id]
+	if !ok {
+		return nil, images.ErrImageNotFound
+	}
+	return im, nil
+}
+
+func (r *fakeRepository) ListImages(ctx context.Context, filter *models.ImageFilter, cursor *models.ImageCursor, limit int) ([]*models.ImageRecord, error) {
+	r.filter = filter
+	return nil, nil
+}
+
+func (r *fakeRepository) GetQuota(ctx context.Context, ownerID uint, since time.Time) (*models.Quota, error) {
+	quota := r.quota
+	return &quota, nil
+}
+
+func (r *fakeRepository) ClaimIdempotencyKey(ctx context.Context, ownerID uint, key, fingerprint string, ttl, lockTimeout time.Duration) (*models.IdempotencyRecord, error) {
+	k := fmt.Sprintf("%d/%s", ownerID, key)
+	if record, ok := r.keys[k]; ok {
+		held := *record
+		return &held, nil
+	}
+	r.keys[k] = &models.IdempotencyRecord{Fingerprint: fingerprint}
+	return nil, nil
+}
+
+func (r *fakeRepository) CompleteIdempotencyKey(ctx context.Context, ownerID uint, key string, imageID uuid.UUID, statusCode int) error {
+	record := r.keys[fmt.Sprintf("%d/%s", ownerID, key)]
+	record.ImageID = imageID
+	record.StatusCode = statusCode
+	return nil
+}
+
+func (r *fakeRepository) ReleaseIdempotencyKey(ctx context.Context, ownerID uint, key string) error {
+	delete(r.keys, fmt.Sprintf("%d/%s", ownerID, key))
+	return nil
+}
+
+func (r *fakeRepository) DeleteImage(ctx context.Context, id uuid.UUID) error {
+	if r.deleteErr != nil {
+		return r.deleteErr
+	}
+	if _, ok := r.images[id]; !ok {
+		return images.ErrImageNotFound
+	}
+	delete(r.images, id)
+	return nil
+}
+
+func TestUploadImage(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           []byte
+		maxPixels      int64
+		readyErr       error
+		expectedErr    error
+		expectedStored bool
+	}{
+		{name: "success", data: append(pngHeader, "body"...), expectedStored: true},
+		{name: "unsupported format", data: []byte("hello, world"), expectedErr: ErrUnsupportedFormat},
+		{name: "outbox not ready", data: append(pngHeader, "body"...), readyErr: errors.New("db down")},
+		{name: "within max pixels", data: encodePNG(t, 40, 30), maxPixels: 1200, expectedStored: true},
+		{name: "over max pixels", data: encodePNG(t, 40, 30), maxPixels: 1199, expectedErr: ErrImageTooLarge},
+		{name: "broken header with max pixels", data: append(pngHeader, "body"...), maxPixels: 1200, expectedErr: ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newFakeRepository()
+			repo.readyErr = tt.readyErr
+			store := storage.NewMemory()
+			cfg := config.New()
+			cfg.SetDefault("image.max_pixels", tt.maxPixels)
+			s := NewService(repo, cfg, store)
+
+			res, err := s.UploadImage(ctx, &models.Image{
+				File:     bytes.NewReader(tt.data),
+				Size:     int64(len(tt.data)),
+				Pipeline: []models.Operation{{Op: "grayscale"}},
+			})
+
+			if tt.expectedErr != nil || tt.readyErr != nil {
+				require.Error(t, err)
+				if tt.expectedErr != nil {
+					assert.True(t, errors.Is(err, tt.expectedErr))
+				}
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusCreated, res.StatusCode)
+				assert.True(t, repo.ready[res.ID])
+				assert.Equal(t, "image/png", repo.images[res.ID].Original.ContentType)
+			}
+
+			objects, err := store.List(ctx, "")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStored, len(objects) == 1)
+			assert.Equal(t, tt.expectedStored, len(repo.images) == 1)
+		})
+	}
+}
+
+func TestGetImageVariant(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	store := storage.NewMemory()
+	s := NewService(repo, config.New(), store)
+	id := uuid.Must(uuid.NewV7())
+	repo.images[id] = &models.ImageRecord{ID: id, ObjectID: id, Status: "processed"}
+	require.NoError(t, store.Put(ctx, storage.VariantKey(id, "small"), strings.NewReader("small"), 5, "image/jpeg"))
+
+	im, err := s.GetImageVariant(ctx, id, "small")
+	require.NoError(t, err)
+	assert.Equal(t, "small", string(im.Data))
+	assert.Equal(t, "image/jpeg", im.ContentType)
+
+	_, err = s.GetImageVariant(ctx, id, "large")
+	assert.True(t, errors.Is(err, ErrVariantNotFound))
+}
+
+func TestDeleteImage(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	store := storage.NewMemory()
+	s := NewService(repo, config.New(), store)
+
+	res, err := s.UploadImage(ctx, &models.Image{
+		File:     bytes.NewReader(pngHeader),
+		Size:     int64(len(pngHeader)),
+		Pipeline: []models.Operation{{Op: "grayscale"}},
+	})
+	require.NoError(t, err)
+	id := res.ID
+	require.NoError(t, store.Put(ctx, storage.VariantKey(id, "processed"), strings.NewReader("v"), 1, "image/png"))
+	other := uuid.Must(uuid.NewV7())
+	require.NoError(t, store.Put(ctx, storage.VariantKey(other, "processed"), strings.NewReader("v"), 1, "image/png"))
+
+	require.NoError(t, s.DeleteImage(ctx, id))
+
+	objects, err := store.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, storage.VariantKey(other, "processed"), objects[0].Key)
+
+	err = s.DeleteImage(ctx, id)
+	assert.True(t, errors.Is(err, images.ErrImageNotFound))
+}
+
+func TestImageOwnership(t *testing.T) {
+	repo := newFakeRepository()
+	store := storage.NewMemory()
+	s := NewService(repo, config.New(), store)
+	owner := auth.WithOwner(context.Background(), 1)
+	other := auth.WithOwner(context.Background(), 2)
+
+	res, err := s.UploadImage(owner, &models.Image{
+		File:     bytes.NewReader(pngHeader),
+		Size:     int64(len(pngHeader)),
+		Pipeline: []models.Operation{{Op: "grayscale"}},
+	})
+	require.NoError(t, err)
+	id := res.ID
+	assert.Equal(t, uint(1), repo.images[id].OwnerID)
+	repo.images[id].Variant = "processed"
+	require.NoError(t, store.Put(owner, storage.VariantKey(id, "processed"), strings.NewReader("v"), 1, "image/png"))
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		expectError error
+	}{
+		{name: "owner", ctx: owner},
+		{name: "auth disabled", ctx: context.Background()},
+		{name: "other key", ctx: other, expectError: images.ErrImageNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.GetProcessedImage(tt.ctx, id)
+
+			if tt.expectError != nil {
+				assert.True(t, errors.Is(err, tt.expectError))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	_, err = s.ListImages(other, &models.ImageFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), repo.filter.OwnerID)
+
+	err = s.DeleteImage(other, id)
+	assert.True(t, errors.Is(err, images.ErrImageNotFound))
+	assert.Contains(t, repo.images, id)
+
+	require.NoError(t, s.DeleteImage(owner, id))
+	assert.NotContains(t, repo.images, id)
+}
+
+func TestUploadImageQuota(t *testing.T) {
+	limit := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		quota         models.Quota
+		expectedQuota string
+		expectRetry   bool
+	}{
+		{name: "anonymous", ctx: context.Background(), quota: models.Quota{Images: 10}},
+		{name: "within defaults", ctx: auth.WithOwner(context.Background(), 1), quota: models.Quota{Images: 9, Bytes: 1000, JobsToday: 4}},
+		{name: "images", ctx: auth.WithOwner(context.Background(), 1), quota: models.Quota{Images: 10}, expectedQuota: QuotaImages},
+		{name: "bytes", ctx: auth.WithOwner(context.Background(), 1), quota: models.Quota{Bytes: 1024 - int64(len(pngHeader)) + 1}, expectedQuota: QuotaBytes},
+		{name: "jobs per day", ctx: auth.WithOwner(context.Background(), 1), quota: models.Quota{JobsToday: 5}, expectedQuota: QuotaJobsPerDay, expectRetry: true},
+		{
+			name:  "key limit over default",
+			ctx:   auth.WithOwner(context.Background(), 1),
+			quota: models.Quota{QuotaLimits: models.QuotaLimits{MaxImages: limit(20)}, Images: 10},
+		},
+		{
+			name:  "unlimited key",
+			ctx:   auth.WithOwner(context.Background(), 1),
+			quota: models.Quota{QuotaLimits: models.QuotaLimits{MaxJobsPerDay: limit(0)}, JobsToday: 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.SetDefault("quota.max_images", 10)
+			cfg.SetDefault("quota.max_bytes", 1024)
+			cfg.SetDefault("quota.max_jobs_per_day", 5)
+			repo := newFakeRepository()
+			repo.quota = tt.quota
+			s := NewService(repo, cfg, storage.NewMemory())
+
+			_, err := s.UploadImage(tt.ctx, &models.Image{
+				File:     bytes.NewReader(pngHeader),
+				Size:     int64(len(pngHeader)),
+				Pipeline: []models.Operation{{Op: "grayscale"}},
+			})
+
+			if tt.expectedQuota == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var quotaErr *QuotaError
+			require.True(t, errors.As(err, &quotaErr))
+			assert.True(t, errors.Is(err, ErrQuotaExceeded))
+			assert.Equal(t, tt.expectedQuota, quotaErr.Quota)
+			assert.Equal(t, tt.expectRetry, quotaErr.RetryAfter > 0)
+			assert.Empty(t, repo.images)
+		})
+	}
+}
+
+func TestUploadImageIdempotency(t *testing.T) {
+	ctx := auth.WithOwner(context.Background(), 1)
+	repo := newFakeRepository()
+	store := storage.NewMemory()
+	s := NewService(repo, config.New(), store)
+	upload := func(ctx context.Context, key string, data []byte, op string) (*models.UploadResult, error) {
+		return s.UploadImage(ctx, &models.Image{
+			File:           bytes.NewReader(data),
+			Size:           int64(len(data)),
+			Pipeline:       []models.Operation{{Op: op}},
+			IdempotencyKey: key,
+		})
+	}
+	png := append(pngHeader, "body"...)
+
+	first, err := upload(ctx, "retry", png, "grayscale")
+	require.NoError(t, err)
+	assert.False(t, first.Replayed)
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		key         string
+		data        []byte
+		op          string
+		expectError error
+		expectNew   bool
+	}{
+		{name: "retry", ctx: ctx, key: "retry", data: png, op: "grayscale"},
+		{name: "different image", ctx: ctx, key: "retry", data: append(pngHeader, "other"...), op: "grayscale", expectError: ErrIdempotencyKeyReused},
+		{name: "different processing", ctx: ctx, key: "retry", data: png, op: "blur", expectError: ErrIdempotencyKeyReused},
+		{name: "other key", ctx: ctx, key: "other", data: png, op: "grayscale", expectNew: true},
+		{name: "other owner", ctx: auth.WithOwner(context.Background(), 2), key: "retry", data: png, op: "grayscale", expectNew: true},
+		{name: "no key", ctx: ctx, data: png, op: "grayscale", expectNew: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images := len(repo.images)
+
+			res, err := upload(tt.ctx, tt.key, tt.data, tt.op)
+
+			if tt.expectError != nil {
+				assert.True(t, errors.Is(err, tt.expectError))
+				assert.Len(t, repo.images, images)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusCreated, res.StatusCode)
+			if tt.expectNew {
+				assert.NotEqual(t, first.ID, res.ID)
+				assert.Len(t, repo.images, images+1)
+			} else {
+				assert.True(t, res.Replayed)
+				assert.Equal(t, first.ID, res.ID)
+				assert.Len(t, repo.images, images)
+			}
+		})
+	}
+
+	t.Run("in progress", func(t *testing.T) {
+		im := &models.Image{
+			File:     bytes.NewReader(png),
+			Pipeline: []models.Operation{{Op: "grayscale"}},
+			Variant:  defaultVariant,
+		}
+		require.NoError(t, hashUpload(im))
+		repo.keys["1/running"] = &models.IdempotencyRecord{Fingerprint: fingerprint(im)}
+
+		_, err := upload(ctx, "running", png, "grayscale")
+		assert.True(t, errors.Is(err, ErrIdempotencyKeyInUse))
+	})
+
+	t.Run("failed upload releases the key", func(t *testing.T) {
+		_, err := upload(ctx, "failed", []byte("hello, world"), "grayscale")
+		require.True(t, errors.Is(err, ErrUnsupportedFormat))
+		assert.NotContains(t, repo.keys, "1/failed")
+	})
+}
+
+func TestUploadImageDeduplication(t *testing.T) {
+	ctx := auth.WithOwner(context.Background(), 1)
+	repo := newFakeRepository()
+	store := storage.NewMemory()
+	s := NewService(repo, config.New(), store)
+	upload := func(ctx context.Context, data []byte, op string) uuid.UUID {
+		res, err := s.UploadImage(ctx, &models.Image{
+			File:     bytes.NewReader(data),
+			Size:     int64(len(data)),
+			Pipeline: []models.Operation{{Op: op}},
+		})
+		require.NoError(t, err)
+		return res.ID
+	}
+	exists := func(key string) bool {
+		_, err := store.Stat(context.Background(), key)
+		return err == nil
+	}
+	png := append(pngHeader, "body"...)
+
+	first := upload(ctx, png, "grayscale")
+	repo.images[first].Status = "processed"
+	require.NoError(t, store.Put(ctx, storage.VariantKey(first, "processed"), strings.NewReader("v"), 1, "image/png"))
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		data        []byte
+		op          string
+		expectShare bool
+	}{
+		{name: "same image and processing", ctx: ctx, data: png, op: "grayscale", expectShare: true},
+		{name: "different processing", ctx: ctx, data: png, op: "blur"},
+		{name: "different image", ctx: ctx, data: append(pngHeader, "other"...), op: "grayscale"},
+		{name: "other owner", ctx: auth.WithOwner(context.Background(), 2), data: png, op: "grayscale"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := upload(tt.ctx, tt.data, tt.op)
+
+			if tt.expectShare {
+				assert.Equal(t, first, repo.images[id].ObjectID)
+				assert.Equal(t, "processed", repo.images[id].Status)
+				assert.False(t, repo.ready[id])
+				assert.False(t, exists(storage.OriginalKey(id)))
+			} else {
+				assert.Equal(t, id, repo.images[id].ObjectID)
+				assert.True(t, repo.ready[id])
+			}
+		})
+	}
+
+	shared := uuid.Nil
+	for id, im := range repo.images {
+		if id != first && im.ObjectID == first {
+			shared = id
+		}
+	}
+	require.NotEqual(t, uuid.Nil, shared)
+
+	require.NoError(t, s.DeleteImage(ctx, first))
+	im, err := s.GetProcessedImage(ctx, shared)
+	require.NoError(t, err)
+	assert.Equal(t, "v", string(im.Data))
+	_, err = s.GetOriginalImage(ctx, shared)
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteImage(ctx, shared))
+	assert.False(t, exists(storage.OriginalKey(first)))
+	assert.False(t, exists(storage.VariantKey(first, "processed")))
+}
+
+func TestListImages(t *testing.T) {
+	columns := []string{
+		"public_id", "status", "variant", "source_format", "processing",
+		"original_filename", "original_content_type", "original_width", "original_height", "original_size",
+		"result_content_type", "result_width", "result_height", "result_size",
+		"attempts", "created_at", "updated_at", "processed_at", "last_error", "error_code", "error_message", "owner_id",
+		"object_id", "content_hash", "spec_hash",
+	}
+	createdAt := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	ids := []uuid.UUID{uuid.Must(uuid.NewV7()), uuid.Must(uuid.NewV7()), uuid.Must(uuid.NewV7())}
+	rows := func(n int) *sqlmock.Rows {
+		r := sqlmock.NewRows(columns)
+		for i := 0; i < n; i++ {
+			r.AddRow(ids[i].String(), "processed", "processed", "png", []byte(`[{"op":"grayscale"}]`),
+				"a.png", "image/png", 640, 480, 1024, "image/png", 640, 480, 512,
+				1, createdAt.Add(time.Duration(i)*time.Minute), createdAt, createdAt, nil, nil, nil, nil, ids[i].String(), nil, nil)
+		}
+		return r
+	}
+	listQuery := `SELECT public_id, .* FROM image`
+
+	tests := []struct {
+		name           string
+		cursor         string
+		mockSetup      func(sqlmock.Sqlmock)
+		expectedIDs    []uuid.UUID
+		expectedCursor *models.ImageCursor
+		expectError    error
+	}{
+		{
+			name: "more rows than limit",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery + ` ORDER BY created_at ASC, public_id ASC LIMIT \$1`).
+					WithArgs(3).
+					WillReturnRows(rows(3))
+			},
+			expectedIDs:    ids[:2],
+			expectedCursor: &models.ImageCursor{CreatedAt: createdAt.Add(time.Minute), ID: ids[1]},
+		},
+		{
+			name: "rows up to limit",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery + ` ORDER BY created_at ASC, public_id ASC LIMIT \$1`).
+					WithArgs(3).
+					WillReturnRows(rows(2))
+			},
+			expectedIDs: ids[:2],
+		},
+		{
+			name: "cursor",
+			cursor: func() string {
+				cursor, err := encodeCursor(&models.ImageCursor{CreatedAt: createdAt.Add(time.Minute), ID: ids[1]})
+				require.NoError(t, err)
+				return cursor
+			}(),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery+` WHERE \(created_at, public_id\) > \(\$1, \$2\) ORDER BY created_at ASC, public_id ASC LIMIT \$3`).
+					WithArgs(createdAt.Add(time.Minute), ids[1], 3).
+					WillReturnRows(rows(1))
+			},
+			expectedIDs: ids[:1],
+		},
+		{name: "cursor not base64", cursor: "not a cursor!", expectError: ErrInvalidCursor},
+		{name: "cursor not json", cursor: base64.RawURLEncoding.EncodeToString([]byte("nope")), expectError: ErrInvalidCursor},
+		{name: "cursor without id", cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"created_at":"2025-12-01T12:00:00Z"}`)), expectError: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			if tt.mockSetup != nil {
+				tt.mockSetup(mock)
+			}
+			s := NewService(images.NewRepository(&dbpg.DB{Master: db}), config.New(), storage.NewMemory())
+
+			page, err := s.ListImages(context.Background(), &models.ImageFilter{Sort: "asc", Limit: 2, Cursor: tt.cursor})
+
+			if tt.expectError != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.expectError))
+			} else {
+				require.NoError(t, err)
+				got := []uuid.UUID{}
+				for _, im := range page.Images {
+					got = append(got, im.ID)
+				}
+				assert.Equal(t, tt.expectedIDs, got)
+				if tt.expectedCursor == nil {
+					assert.Empty(t, page.NextCursor)
+				} else {
+					cursor, err := decodeCursor(page.NextCursor)
+					require.NoError(t, err)
+					assert.True(t, tt.expectedCursor.CreatedAt.Equal(cursor.CreatedAt))
+					assert.Equal(t, tt.expectedCursor.ID, cursor.ID)
+				}
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
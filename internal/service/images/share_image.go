@@ -0,0 +1,31 @@
+package images
+
+import (
+	"context"
+	"errors"
+
+	"github.com/avraam311/image-processor/internal/metrics"
+	"github.com/avraam311/image-processor/internal/models"
+	"github.com/avraam311/image-processor/internal/repository/images"
+
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/google/uuid"
+)
+
+// shareImage stores the upload as a copy of an identical image of the same
+// owner that is already processed, sharing its objects instead of storing
+// and processing the picture again. It reports whether there was such an
+// image; failing to look for one only costs the processing.
+func (s *Service) shareImage(ctx context.Context, record *models.ImageRecord) (uuid.UUID, bool) {
+	id, err := s.repo.ShareImage(ctx, record)
+	if err != nil {
+		if !errors.Is(err, images.ErrImageNotFound) {
+			zlog.Logger.Warn().Err(err).Msg("service/share_image.go - failed to share image")
+		}
+		return uuid.Nil, false
+	}
+	metrics.DeduplicatedUploads.Inc()
+
+	return id, true
+}
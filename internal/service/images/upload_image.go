@@ -1,58 +1,185 @@
 package images
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
+	"io"
+	"net/http"
 
+	"github.com/avraam311/image-processor/internal/auth"
+	"github.com/avraam311/image-processor/internal/imageformat"
+	"github.com/avraam311/image-processor/internal/infra/storage"
+	"github.com/avraam311/image-processor/internal/metrics"
 	"github.com/avraam311/image-processor/internal/models"
 
-	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
 
-	"github.com/minio/minio-go"
+	"github.com/google/uuid"
 )
 
 const (
 	imageStatusInProcess = "in process"
-	imageFormat          = "image/jpeg"
+	defaultVariant       = "processed"
 )
 
-func (s *Service) UploadImage(ctx context.Context, im *models.Image) (uint, error) {
-	id, err := s.repo.SetImageStatus(ctx, imageStatusInProcess)
+// UploadImage stores the original and queues its processing. An image the
+// API key already has processed the same way is not processed again, the
+// upload shares its objects. Uploads with an idempotency key run once per
+// key, retries get the result of the first one.
+func (s *Service) UploadImage(ctx context.Context, im *models.Image) (*models.UploadResult, error) {
+	if im.Variant == "" {
+		im.Variant = defaultVariant
+	}
+	if err := hashUpload(im); err != nil {
+		return nil, fmt.Errorf("service/upload_image.go - %w", err)
+	}
+	owner, ok := auth.Owner(ctx)
+	if im.IdempotencyKey == "" {
+		return s.upload(ctx, im, owner, ok)
+	}
+
+	replay, err := s.claimIdempotencyKey(ctx, owner, im)
 	if err != nil {
-		return 0, fmt.Errorf("service/upload_image.go - %w", err)
+		return nil, fmt.Errorf("service/upload_image.go - %w", err)
+	}
+	if replay != nil {
+		return replay, nil
 	}
+	res, err := s.upload(ctx, im, owner, ok)
+	s.finishIdempotencyKey(ctx, owner, im.IdempotencyKey, res, err)
 
-	prodStrategy := retry.Strategy{
-		Attempts: s.cfg.GetInt("retry.attempts"),
-		Delay:    s.cfg.GetDuration("retry.delay"),
-		Backoff:  s.cfg.GetFloat64("retry.backoff"),
+	return res, err
+}
+
+// upload runs an upload, hasOwner tells whether it is made with an API key.
+func (s *Service) upload(ctx context.Context, im *models.Image, owner uint, hasOwner bool) (*models.UploadResult, error) {
+	sourceFormat, err := sniffFormat(im)
+	if err != nil {
+		return nil, fmt.Errorf("service/upload_image.go - %w", err)
 	}
-	imageKey, err := json.Marshal(id)
+	if err := checkPixels(im, s.cfg.GetInt64("image.max_pixels")); err != nil {
+		return nil, fmt.Errorf("service/upload_image.go - %w", err)
+	}
+
+	if hasOwner {
+		if err := s.checkQuota(ctx, owner, im.Size); err != nil {
+			return nil, fmt.Errorf("service/upload_image.go - %w", err)
+		}
+	}
+	record := models.ImageRecord{
+		OwnerID:      owner,
+		Status:       imageStatusInProcess,
+		Variant:      im.Variant,
+		SourceFormat: sourceFormat,
+		Processing:   im.Pipeline,
+		Original: models.ImageInfo{
+			FileName:    im.FileName,
+			ContentType: im.ContentType,
+			Size:        im.Size,
+		},
+		ContentHash: im.ContentHash,
+		SpecHash:    im.SpecHash,
+	}
+	if id, ok := s.shareImage(ctx, &record); ok {
+		metrics.UploadSize.Observe(float64(im.Size))
+		return &models.UploadResult{ID: id, StatusCode: http.StatusCreated}, nil
+	}
+
+	payload, err := jobPayload(im)
 	if err != nil {
-		return 0, fmt.Errorf("service/upload_image.go - failed to marshal id into json - %w", err)
+		return nil, fmt.Errorf("service/upload_image.go - %w", err)
 	}
-	imageValue, err := json.Marshal(im)
+	id, err := s.repo.SetImageStatus(ctx, &record, payload)
 	if err != nil {
-		return 0, fmt.Errorf("service/upload_image.go - failed to marshal processing into json - %w", err)
+		return nil, fmt.Errorf("service/upload_image.go - %w", err)
+	}
+
+	err = s.store.Put(ctx, storage.OriginalKey(id), im.File, im.Size, im.ContentType)
+	if err != nil {
+		s.discardUpload(ctx, id)
+		return nil, fmt.Errorf("service/upload_image.go - failed to put image in storage - %w", err)
+	}
+
+	// The outbox relay publishes the job only from here on, so the worker
+	// never sees a job without its original.
+	if err := s.repo.MarkOutboxReady(ctx, id); err != nil {
+		s.discardUpload(ctx, id)
+		return nil, fmt.Errorf("service/upload_image.go - %w", err)
+	}
+	metrics.UploadSize.Observe(float64(im.Size))
+
+	return &models.UploadResult{ID: id, StatusCode: http.StatusCreated}, nil
+}
+
+// discardUpload removes the record and whatever was stored of an upload that
+// failed half way. Leftovers are removed later by the outbox relay.
+func (s *Service) discardUpload(ctx context.Context, id uuid.UUID) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.repo.DeleteImage(ctx, id); err != nil {
+		zlog.Logger.Warn().Err(err).Stringer("image", id).Msg("service/upload_image.go - failed to delete partial upload")
+	}
+	if err := s.store.Delete(ctx, storage.OriginalKey(id)); err != nil {
+		zlog.Logger.Warn().Err(err).Stringer("image", id).Msg("service/upload_image.go - failed to remove partial upload")
+	}
+}
+
+// sniffFormat detects the format from the leading bytes of the upload and
+// replaces the client supplied content type with the detected one. The
+// upload is rewound, hashUpload left it seekable.
+func sniffFormat(im *models.Image) (string, error) {
+	header := make([]byte, imageformat.SniffLen)
+	n, err := rewound(im.File, func(r io.Reader) (int, error) {
+		return io.ReadFull(r, header)
+	})
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read image header - %w", err)
+	}
+	header = header[:n]
+
+	format, ok := imageformat.Detect(header)
+	if !ok {
+		return "", ErrUnsupportedFormat
+	}
+	im.ContentType = imageformat.ContentType(format)
+
+	return format, nil
+}
+
+// checkPixels rejects an image whose header claims more than maxPixels, 0 is
+// unlimited, so the worker never decodes it. The upload is rewound.
+func checkPixels(im *models.Image, maxPixels int64) error {
+	if maxPixels <= 0 {
+		return nil
+	}
+
+	_, err := rewound(im.File, func(r io.Reader) (int, error) {
+		return 0, imageformat.CheckPixels(r, maxPixels)
+	})
+	if errors.Is(err, imageformat.ErrTooManyPixels) {
+		return fmt.Errorf("%w: %w", ErrImageTooLarge, err)
 	}
-	err = s.prod.SendWithRetry(ctx, prodStrategy, imageKey, imageValue)
 	if err != nil {
-		return 0, fmt.Errorf("service/upload_image.go - failed to send request to kafka - %w", err)
+		return fmt.Errorf("%w: %w", ErrUnsupportedFormat, err)
 	}
 
-	objectName := strconv.Itoa(int(id))
-	imageAsReader := bytes.NewReader(imageValue)
-	size := int64(len(imageValue))
-	putObjectOptions := minio.PutObjectOptions{
-		ContentType: imageFormat,
+	return nil
+}
+
+// rewound runs read on file and seeks file back to where it started.
+func rewound(file io.Reader, read func(io.Reader) (int, error)) (int, error) {
+	seeker, ok := file.(io.ReadSeeker)
+	if !ok {
+		return 0, errors.New("image is not seekable")
 	}
-	_, err = s.s3.Minio.PutObject(s.cfg.GetString("s3.bucket_name"), objectName, imageAsReader, size, putObjectOptions)
+	start, err := seeker.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return 0, fmt.Errorf("service/upload_image.go - failed to put image in s3 - %w", err)
+		return 0, fmt.Errorf("failed to seek image - %w", err)
+	}
+	n, readErr := read(seeker)
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to rewind image - %w", err)
 	}
 
-	return id, nil
+	return n, readErr
 }
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE image
+    ADD COLUMN IF NOT EXISTS variant VARCHAR(64) NOT NULL DEFAULT 'processed';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE image
+    DROP COLUMN IF EXISTS variant;
+-- +goose StatementEnd
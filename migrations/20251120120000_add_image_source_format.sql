@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE image
+    ADD COLUMN IF NOT EXISTS source_format VARCHAR(10) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE image
+    DROP COLUMN IF EXISTS source_format;
+-- +goose StatementEnd
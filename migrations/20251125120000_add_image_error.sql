@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE image
+    ADD COLUMN IF NOT EXISTS error_code VARCHAR(32),
+    ADD COLUMN IF NOT EXISTS error_message TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE image
+    DROP COLUMN IF EXISTS error_code,
+    DROP COLUMN IF EXISTS error_message;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE image
+    ADD COLUMN IF NOT EXISTS processing JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS original_width INTEGER,
+    ADD COLUMN IF NOT EXISTS original_height INTEGER,
+    ADD COLUMN IF NOT EXISTS original_size BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS result_width INTEGER,
+    ADD COLUMN IF NOT EXISTS result_height INTEGER,
+    ADD COLUMN IF NOT EXISTS result_size BIGINT,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS processed_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE image
+    DROP COLUMN IF EXISTS processing,
+    DROP COLUMN IF EXISTS original_width,
+    DROP COLUMN IF EXISTS original_height,
+    DROP COLUMN IF EXISTS original_size,
+    DROP COLUMN IF EXISTS result_width,
+    DROP COLUMN IF EXISTS result_height,
+    DROP COLUMN IF EXISTS result_size,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS processed_at;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE image
+    ADD COLUMN IF NOT EXISTS original_filename TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS original_content_type VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS result_content_type VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error TEXT;
+
+ALTER TABLE image
+    ALTER COLUMN status TYPE VARCHAR(32);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE image
+    ALTER COLUMN status TYPE VARCHAR(15);
+
+ALTER TABLE image
+    DROP COLUMN IF EXISTS original_filename,
+    DROP COLUMN IF EXISTS original_content_type,
+    DROP COLUMN IF EXISTS result_content_type,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS last_error;
+-- +goose StatementEnd
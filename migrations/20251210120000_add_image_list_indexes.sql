@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS image_created_at_id_idx ON image (created_at, id);
+CREATE INDEX IF NOT EXISTS image_status_created_at_id_idx ON image (status, created_at, id);
+CREATE INDEX IF NOT EXISTS image_source_format_created_at_id_idx ON image (source_format, created_at, id);
+CREATE INDEX IF NOT EXISTS image_processing_idx ON image USING GIN (processing jsonb_path_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS image_processing_idx;
+DROP INDEX IF EXISTS image_source_format_created_at_id_idx;
+DROP INDEX IF EXISTS image_status_created_at_id_idx;
+DROP INDEX IF EXISTS image_created_at_id_idx;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    image_id INTEGER NOT NULL REFERENCES image (id) ON DELETE CASCADE,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ready_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_ready_idx ON outbox (id) WHERE ready_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (created_at) WHERE ready_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox;
+-- +goose StatementEnd
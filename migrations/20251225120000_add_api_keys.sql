@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS api_key (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(128) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+ALTER TABLE image ADD COLUMN IF NOT EXISTS owner_id INTEGER REFERENCES api_key (id);
+
+CREATE INDEX IF NOT EXISTS image_owner_id_created_at_idx ON image (owner_id, created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS image_owner_id_created_at_idx;
+ALTER TABLE image DROP COLUMN IF EXISTS owner_id;
+DROP TABLE IF EXISTS api_key;
+-- +goose StatementEnd
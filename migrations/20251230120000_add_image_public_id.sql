@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing rows get random (v4) ids, new images are inserted with UUIDv7.
+ALTER TABLE image ADD COLUMN IF NOT EXISTS public_id UUID;
+UPDATE image SET public_id = gen_random_uuid() WHERE public_id IS NULL;
+ALTER TABLE image ALTER COLUMN public_id SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS image_public_id_idx ON image (public_id);
+
+-- Listings are ordered and paged by (created_at, public_id) now.
+DROP INDEX IF EXISTS image_created_at_id_idx;
+DROP INDEX IF EXISTS image_status_created_at_id_idx;
+DROP INDEX IF EXISTS image_source_format_created_at_id_idx;
+DROP INDEX IF EXISTS image_owner_id_created_at_idx;
+CREATE INDEX IF NOT EXISTS image_created_at_public_id_idx ON image (created_at, public_id);
+CREATE INDEX IF NOT EXISTS image_status_created_at_public_id_idx ON image (status, created_at, public_id);
+CREATE INDEX IF NOT EXISTS image_source_format_created_at_public_id_idx ON image (source_format, created_at, public_id);
+CREATE INDEX IF NOT EXISTS image_owner_id_created_at_public_id_idx ON image (owner_id, created_at, public_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS image_owner_id_created_at_public_id_idx;
+DROP INDEX IF EXISTS image_source_format_created_at_public_id_idx;
+DROP INDEX IF EXISTS image_status_created_at_public_id_idx;
+DROP INDEX IF EXISTS image_created_at_public_id_idx;
+CREATE INDEX IF NOT EXISTS image_created_at_id_idx ON image (created_at, id);
+CREATE INDEX IF NOT EXISTS image_status_created_at_id_idx ON image (status, created_at, id);
+CREATE INDEX IF NOT EXISTS image_source_format_created_at_id_idx ON image (source_format, created_at, id);
+CREATE INDEX IF NOT EXISTS image_owner_id_created_at_idx ON image (owner_id, created_at, id);
+
+DROP INDEX IF EXISTS image_public_id_idx;
+ALTER TABLE image DROP COLUMN IF EXISTS public_id;
+-- +goose StatementEnd
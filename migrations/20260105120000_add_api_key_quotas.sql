@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- NULL limits fall back to the quota section of the config.
+ALTER TABLE api_key
+    ADD COLUMN IF NOT EXISTS max_images BIGINT,
+    ADD COLUMN IF NOT EXISTS max_bytes BIGINT,
+    ADD COLUMN IF NOT EXISTS max_jobs_per_day BIGINT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_key
+    DROP COLUMN IF EXISTS max_images,
+    DROP COLUMN IF EXISTS max_bytes,
+    DROP COLUMN IF EXISTS max_jobs_per_day;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- owner_id is the api key of the upload, 0 for anonymous uploads. image_id
+-- and status_code are set once the first upload with the key completes.
+CREATE TABLE IF NOT EXISTS idempotency_key (
+    owner_id INTEGER NOT NULL DEFAULT 0,
+    key VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL,
+    image_id UUID,
+    status_code INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (owner_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_key_expires_at_idx ON idempotency_key (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_key;
+-- +goose StatementEnd
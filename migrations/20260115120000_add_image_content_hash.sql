@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- object_id is the id the objects of the image are stored under: its own
+-- public id, or the one of an identical image whose objects it shares.
+-- content_hash is the SHA-256 of the original and spec_hash the SHA-256 of the
+-- processing job, both NULL for images uploaded before.
+ALTER TABLE image
+    ADD COLUMN IF NOT EXISTS object_id UUID,
+    ADD COLUMN IF NOT EXISTS content_hash CHAR(64),
+    ADD COLUMN IF NOT EXISTS spec_hash CHAR(64);
+UPDATE image SET object_id = public_id WHERE object_id IS NULL;
+ALTER TABLE image ALTER COLUMN object_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS image_object_id_idx ON image (object_id);
+CREATE INDEX IF NOT EXISTS image_owner_id_content_hash_spec_hash_idx ON image (owner_id, content_hash, spec_hash) WHERE status = 'processed';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS image_owner_id_content_hash_spec_hash_idx;
+DROP INDEX IF EXISTS image_object_id_idx;
+ALTER TABLE image
+    DROP COLUMN IF EXISTS object_id,
+    DROP COLUMN IF EXISTS content_hash,
+    DROP COLUMN IF EXISTS spec_hash;
+-- +goose StatementEnd
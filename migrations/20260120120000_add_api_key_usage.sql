@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Jobs an API key queued per UTC day, day is the start of the day. Unlike
+-- image rows, the count doesn't drop when images are deleted.
+CREATE TABLE IF NOT EXISTS api_key_usage (
+    owner_id INTEGER NOT NULL REFERENCES api_key (id) ON DELETE CASCADE,
+    day TIMESTAMPTZ NOT NULL,
+    jobs BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (owner_id, day)
+);
+
+INSERT INTO api_key_usage (owner_id, day, jobs)
+SELECT owner_id, date_trunc('day', created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', count(*)
+FROM image
+WHERE owner_id IS NOT NULL
+GROUP BY 1, 2
+ON CONFLICT (owner_id, day) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_key_usage;
+-- +goose StatementEnd